@@ -32,7 +32,7 @@ func main() {
 		log.Fatal("Could not intialize database", err)
 	}
 
-	s := scheduler.New(storage)
+	s := scheduler.New(storage, scheduler.WithSignalHandling())
 
 	// Start a task without arguments
 	if _, err := s.RunAfter(30*time.Second, TaskWithoutArgs); err != nil {
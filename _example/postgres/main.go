@@ -29,7 +29,7 @@ func main() {
 
 	s := scheduler.New(storage)
 
-	go func(s scheduler.Scheduler, store io.Closer) {
+	go func(s *scheduler.Scheduler, store io.Closer) {
 		time.Sleep(time.Second * 10)
 		// store.Close()
 		s.Stop()
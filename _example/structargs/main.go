@@ -46,7 +46,7 @@ func main() {
 		log.Fatal("Could not intialize database", err)
 	}
 
-	s := scheduler.New(storage)
+	s := scheduler.New(storage, scheduler.WithSignalHandling())
 
 	dob, _ := time.Parse(DateLayout, time.Now().Format(DateLayout))
 	person := Person{
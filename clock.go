@@ -0,0 +1,36 @@
+package scheduler
+
+import "time"
+
+// Clock provides the current time and the timers used by the scheduler's dispatcher.
+// It can be replaced using WithClock, for instance to control time in tests.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a timer created by a Clock, it behaves like time.Timer.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// realClock is the default Clock, backed by the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (timer realTimer) C() <-chan time.Time {
+	return timer.Timer.C
+}
@@ -0,0 +1,255 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/rakanalh/scheduler/task"
+)
+
+// ResultHandler is called with the result of every task execution.
+type ResultHandler func(task.Result)
+
+// PanicHandler is called when a task's function panics. The panic is also
+// available as the result's error.
+type PanicHandler func(result task.Result, panicErr *task.PanicError)
+
+// logPanic is the default panic handler, it logs the panic along with the stack trace.
+func (scheduler *Scheduler) logPanic(result task.Result, panicErr *task.PanicError) {
+	scheduler.logger.Error("Task panicked", "task", result.TaskID, "func", result.FuncName,
+		"panic", panicErr.Value, "stack", string(panicErr.Stack))
+}
+
+// OnResult registers a handler which is called after every execution of a task, whether
+// it succeeded or not. Handlers are called from the goroutine which executed the task.
+func (scheduler *Scheduler) OnResult(handler ResultHandler) {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	scheduler.resultHandlers = append(scheduler.resultHandlers, handler)
+}
+
+// OnPanic sets the handler which is called when a task's function panics.
+// Panics are always recovered so that a failing task can't bring down the process,
+// the default handler logs them along with their stack trace.
+func (scheduler *Scheduler) OnPanic(handler PanicHandler) {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	scheduler.panicHandler = handler
+}
+
+// LastResult returns the result of the latest execution of a scheduled task.
+// Results are only kept for as long as the task is scheduled.
+func (scheduler *Scheduler) LastResult(taskID task.ID) (task.Result, bool) {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	result, ok := scheduler.results[taskID]
+	return result, ok
+}
+
+// execution is a single run of a task which is in progress.
+type execution struct {
+	taskID  task.ID
+	task    *task.Task
+	attempt int
+	ctx     context.Context
+	cancel  context.CancelFunc
+	// pooled is set when the execution was handed over to the worker pool.
+	pooled bool
+	// superseded is set when the execution was cancelled by a newer run of the task.
+	superseded bool
+	// catchUp is set when the next missed run of the task is queued once the execution finished.
+	catchUp bool
+	// scheduledAt is the time at which the run was due.
+	scheduledAt time.Time
+	// manual is set for runs triggered using TriggerNow, which don't affect the task's schedule.
+	manual bool
+}
+
+// scheduledTimeKey is the context key of the time at which a run was due.
+type scheduledTimeKey struct{}
+
+// ScheduledTime returns the time at which the run being executed was due, from the context
+// passed to the task's function. It's the time of the missed run for runs executed to catch up.
+func ScheduledTime(ctx context.Context) (time.Time, bool) {
+	scheduledAt, ok := ctx.Value(scheduledTimeKey{}).(time.Time)
+	return scheduledAt, ok
+}
+
+// startExecution prepares the execution of a task which is due at scheduledAt and tracks it as running.
+// The execution's context is cancelled when the scheduler stops, the task is cancelled
+// or the task's timeout expires. It must be called with scheduler.mu held.
+func (scheduler *Scheduler) startExecution(taskID task.ID, t *task.Task, scheduledAt time.Time) *execution {
+	ctx, cancel := context.WithCancel(context.WithValue(scheduler.ctx, scheduledTimeKey{}, scheduledAt))
+	exec := &execution{
+		taskID:      taskID,
+		task:        t,
+		attempt:     t.Attempt + 1,
+		ctx:         ctx,
+		cancel:      cancel,
+		scheduledAt: scheduledAt,
+	}
+
+	if scheduler.running[taskID] == nil {
+		scheduler.running[taskID] = make(map[*execution]struct{})
+	}
+	scheduler.running[taskID][exec] = struct{}{}
+	scheduler.funcRunning[t.Func.Name]++
+	scheduler.runCounts[taskID]++
+	scheduler.inFlight.Add(1)
+	return exec
+}
+
+// discardExecution forgets an execution which couldn't be submitted.
+// It must be called with scheduler.mu held.
+func (scheduler *Scheduler) discardExecution(exec *execution) {
+	scheduler.untrackExecution(exec)
+	scheduler.runCounts[exec.taskID]--
+	scheduler.inFlight.Done()
+}
+
+// untrackExecution stops tracking the execution as running.
+// It must be called with scheduler.mu held.
+func (scheduler *Scheduler) untrackExecution(exec *execution) {
+	exec.cancel()
+	delete(scheduler.running[exec.taskID], exec)
+	if len(scheduler.running[exec.taskID]) == 0 {
+		delete(scheduler.running, exec.taskID)
+	}
+
+	name := exec.task.Func.Name
+	scheduler.funcRunning[name]--
+	if scheduler.funcRunning[name] == 0 {
+		delete(scheduler.funcRunning, name)
+	}
+	scheduler.releaseForFunc(name)
+
+	if exec.pooled {
+		scheduler.poolLoad--
+		if scheduler.poolBlocked {
+			scheduler.poolBlocked = false
+			scheduler.wake()
+		}
+	}
+}
+
+// cancelRunning cancels the context of all running executions of the task.
+// It must be called with scheduler.mu held.
+func (scheduler *Scheduler) cancelRunning(taskID task.ID) {
+	for exec := range scheduler.running[taskID] {
+		exec.cancel()
+	}
+}
+
+// execute calls the task's function through the middlewares and completes the execution.
+// The function isn't called when the execution was cancelled while it waited for a worker.
+func (scheduler *Scheduler) execute(exec *execution) {
+	startedAt := scheduler.clock.Now()
+	if err := exec.ctx.Err(); err != nil {
+		scheduler.complete(exec, task.Result{
+			FuncName:   exec.task.Func.Name,
+			StartedAt:  startedAt,
+			FinishedAt: startedAt,
+			Err:        err,
+		})
+		return
+	}
+
+	ctx := exec.ctx
+	if exec.task.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, exec.task.Timeout)
+		defer cancel()
+	}
+
+	scheduler.mu.Lock()
+	hooks := scheduler.hooks
+	scheduler.mu.Unlock()
+	run := exec.runInfo()
+	for _, hook := range hooks.start {
+		scheduler.callHook("OnStart hook", func() { hook(run) })
+	}
+
+	result := exec.handle(ctx, hooks.middlewares, run)
+	result.FuncName = exec.task.Func.Name
+	result.StartedAt, result.FinishedAt = startedAt, scheduler.clock.Now()
+	scheduler.complete(exec, result)
+}
+
+// complete records the result of the execution and reports it to the result handlers.
+func (scheduler *Scheduler) complete(exec *execution, result task.Result) {
+	defer scheduler.inFlight.Done()
+
+	result.TaskID = exec.taskID
+	result.Attempt = exec.attempt
+	result.ScheduledAt = exec.scheduledAt
+	result.Manual = exec.manual
+
+	scheduler.mu.Lock()
+	scheduler.untrackExecution(exec)
+	if exec.superseded || exec.manual {
+		// The newer run takes care of the schedule, manual runs don't affect it
+		if scheduler.tasks[exec.taskID] == exec.task {
+			scheduler.results[exec.taskID] = result
+		}
+	} else {
+		scheduler.finishExecution(exec.taskID, exec.task, result)
+	}
+	if exec.catchUp && scheduler.tasks[exec.taskID] == exec.task && scheduler.queue.byID[exec.taskID] == nil {
+		scheduler.queue.schedule(exec.taskID, exec.task)
+		scheduler.wake()
+	}
+	scheduler.startQueuedRun(exec.taskID)
+	handlers := scheduler.resultHandlers
+	panicHandler := scheduler.panicHandler
+	hooks := scheduler.hooks
+	scheduler.unlock()
+
+	scheduler.recordHistory(result)
+	if panicErr, ok := result.Err.(*task.PanicError); ok && panicHandler != nil {
+		scheduler.callHook("OnPanic handler", func() { panicHandler(result, panicErr) })
+	}
+	scheduler.reportResult(result, handlers, hooks)
+}
+
+// finishExecution records the result of the execution and schedules a retry if the
+// run failed and the task's retry policy allows it. One-off tasks are removed once they
+// don't have to be retried anymore. It must be called with scheduler.mu held.
+func (scheduler *Scheduler) finishExecution(taskID task.ID, t *task.Task, result task.Result) {
+	if scheduler.tasks[taskID] != t {
+		// The task was cancelled while it was running
+		return
+	}
+	scheduler.results[taskID] = result
+
+	if !result.Succeeded() && t.Retry != nil {
+		schedule := t.CurrentSchedule()
+		dueAt := schedule.LastRun
+		if !schedule.IsRecurring && t.Attempt == 0 {
+			dueAt = schedule.NextRun
+		}
+
+		retryAt, ok := t.Retry.RetryAt(dueAt, result.FinishedAt, t.Attempt+1)
+		// Recurring tasks aren't retried past their next regular run
+		if ok && (!schedule.IsRecurring || retryAt.Before(schedule.NextRun)) {
+			t.ScheduleRetry(retryAt)
+			scheduler.queue.schedule(taskID, t)
+			scheduler.queueStore((*storeBridge).Update, t)
+			scheduler.wake()
+			return
+		}
+	}
+
+	if !t.IsRecurring {
+		// Removed before its retries are reset, the ID of a one-off task is derived from the time it was due
+		scheduler.removeTask(taskID, t)
+		return
+	}
+	wasRetried := t.Attempt > 0
+	t.ResetRetries()
+	if wasRetried {
+		scheduler.queueStore((*storeBridge).Update, t)
+	}
+}
@@ -0,0 +1,228 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/rakanalh/scheduler/storage"
+	"github.com/rakanalh/scheduler/task"
+)
+
+// historyTimeFormat formats times with a fixed width so that history stores can compare them as strings.
+const historyTimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+// maxPruneInterval is the longest time between two prunings of the history.
+const maxPruneInterval = time.Hour
+
+// ErrHistoryDisabled is returned by History when the scheduler has no history store.
+var ErrHistoryDisabled = errors.New("Execution history is not enabled")
+
+// Outcome describes how an execution of a task ended.
+type Outcome string
+
+const (
+	// OutcomeSucceeded is the outcome of executions whose function returned no error.
+	OutcomeSucceeded Outcome = "succeeded"
+	// OutcomeFailed is the outcome of executions whose function returned an error.
+	OutcomeFailed Outcome = "failed"
+	// OutcomePanicked is the outcome of executions whose function panicked.
+	OutcomePanicked Outcome = "panicked"
+	// OutcomeTimedOut is the outcome of executions which exceeded the task's timeout.
+	OutcomeTimedOut Outcome = "timed out"
+	// OutcomeCancelled is the outcome of executions which were cancelled.
+	OutcomeCancelled Outcome = "cancelled"
+	// OutcomeSkipped is the outcome of runs skipped because the task was still running.
+	OutcomeSkipped Outcome = "skipped"
+	// OutcomeDropped is the outcome of runs dropped because the worker pool's queue was full.
+	OutcomeDropped Outcome = "dropped"
+)
+
+// outcomeOf returns the outcome of the execution which produced the result.
+func outcomeOf(result task.Result) Outcome {
+	if _, ok := result.Err.(*task.PanicError); ok {
+		return OutcomePanicked
+	}
+	switch result.Err {
+	case nil:
+		return OutcomeSucceeded
+	case ErrOverlap:
+		return OutcomeSkipped
+	case ErrQueueFull:
+		return OutcomeDropped
+	case context.DeadlineExceeded:
+		return OutcomeTimedOut
+	case context.Canceled:
+		return OutcomeCancelled
+	}
+	return OutcomeFailed
+}
+
+// HistoryEntry is the record of an execution of a task, kept by the history store.
+type HistoryEntry struct {
+	TaskID      task.ID
+	FuncName    string
+	ScheduledAt time.Time
+	StartedAt   time.Time
+	FinishedAt  time.Time
+	Duration    time.Duration
+	Outcome     Outcome
+	// Error is the text of the execution's error, empty when it succeeded.
+	Error   string
+	Attempt int
+	// NodeID identifies the scheduler which executed the task.
+	NodeID string
+	// Manual is set for runs which were triggered using TriggerNow.
+	Manual bool
+}
+
+// HistoryFilter selects the entries returned by History. Zero values select everything.
+type HistoryFilter struct {
+	// Since and Until select the executions which started in [Since, Until).
+	Since time.Time
+	Until time.Time
+	// Outcome selects the executions which ended with the outcome.
+	Outcome Outcome
+	// Limit is the maximum number of entries returned, the most recent ones are kept.
+	Limit int
+}
+
+// WithHistory records every execution of the tasks in the history store, including the runs which
+// were skipped or dropped. Entries older than retention are pruned, zero keeps them forever.
+// The store can be the same as the task store when it implements both interfaces.
+func WithHistory(store storage.HistoryStore, retention time.Duration) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.history = store
+		scheduler.historyRetention = retention
+	}
+}
+
+// WithNodeID sets the ID of the scheduler recorded in the history, the host name by default.
+func WithNodeID(id string) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.nodeID = id
+	}
+}
+
+// History returns the recorded executions of the task selected by the filter, most recent first.
+// The executions of all tasks are returned when taskID is empty.
+func (scheduler *Scheduler) History(taskID task.ID, filter HistoryFilter) ([]HistoryEntry, error) {
+	if scheduler.history == nil {
+		return nil, ErrHistoryDisabled
+	}
+
+	query := storage.HistoryQuery{
+		TaskHash: string(taskID),
+		Outcome:  string(filter.Outcome),
+		Limit:    filter.Limit,
+	}
+	if !filter.Since.IsZero() {
+		query.Since = formatHistoryTime(filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query.Until = formatHistoryTime(filter.Until)
+	}
+	records, err := scheduler.history.FetchRecords(query)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]HistoryEntry, 0, len(records))
+	for _, record := range records {
+		entry, err := historyEntry(record)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// recordHistory adds the result of an execution to the history and prunes the old entries when it's time to.
+func (scheduler *Scheduler) recordHistory(result task.Result) {
+	if scheduler.history == nil {
+		return
+	}
+	manual := "0"
+	if result.Manual {
+		manual = "1"
+	}
+	scheduler.reportError(scheduler.history.AddRecord(storage.HistoryRecord{
+		TaskHash:    string(result.TaskID),
+		Name:        result.FuncName,
+		ScheduledAt: formatHistoryTime(result.ScheduledAt),
+		StartedAt:   formatHistoryTime(result.StartedAt),
+		FinishedAt:  formatHistoryTime(result.FinishedAt),
+		Duration:    result.FinishedAt.Sub(result.StartedAt).String(),
+		Outcome:     string(outcomeOf(result)),
+		Error:       errorText(result.Err),
+		Attempt:     strconv.Itoa(result.Attempt),
+		NodeID:      scheduler.nodeID,
+		Manual:      manual,
+	}))
+
+	if scheduler.historyRetention <= 0 {
+		return
+	}
+	now := scheduler.clock.Now()
+	interval := scheduler.historyRetention
+	if interval > maxPruneInterval {
+		interval = maxPruneInterval
+	}
+	scheduler.mu.Lock()
+	prune := now.Sub(scheduler.lastPrune) >= interval
+	if prune {
+		scheduler.lastPrune = now
+	}
+	scheduler.mu.Unlock()
+	if prune {
+		scheduler.reportError(scheduler.history.PruneRecords(formatHistoryTime(now.Add(-scheduler.historyRetention))))
+	}
+}
+
+func historyEntry(record storage.HistoryRecord) (HistoryEntry, error) {
+	var times [3]time.Time
+	for i, value := range []string{record.ScheduledAt, record.StartedAt, record.FinishedAt} {
+		parsed, err := time.Parse(historyTimeFormat, value)
+		if err != nil {
+			return HistoryEntry{}, err
+		}
+		times[i] = parsed
+	}
+
+	duration, err := time.ParseDuration(record.Duration)
+	if err != nil {
+		return HistoryEntry{}, err
+	}
+
+	attempt, err := strconv.Atoi(record.Attempt)
+	if err != nil {
+		return HistoryEntry{}, err
+	}
+
+	return HistoryEntry{
+		TaskID:      task.ID(record.TaskHash),
+		FuncName:    record.Name,
+		ScheduledAt: times[0],
+		StartedAt:   times[1],
+		FinishedAt:  times[2],
+		Duration:    duration,
+		Outcome:     Outcome(record.Outcome),
+		Error:       record.Error,
+		Attempt:     attempt,
+		NodeID:      record.NodeID,
+		Manual:      record.Manual == "1",
+	}, nil
+}
+
+func formatHistoryTime(t time.Time) string {
+	return t.UTC().Format(historyTimeFormat)
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
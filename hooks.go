@@ -0,0 +1,218 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/rakanalh/scheduler/task"
+)
+
+// RunInfo describes a run of a task to the middlewares and the lifecycle hooks.
+type RunInfo struct {
+	TaskID   task.ID
+	FuncName string
+	Params   []task.Param
+	// ScheduledAt is the time at which the run was due, or was triggered for manual runs.
+	ScheduledAt time.Time
+	// Attempt is the attempt number of the run, starting at 1 and increasing with every retry.
+	Attempt int
+	// Manual is set for runs which were triggered using TriggerNow.
+	Manual bool
+}
+
+// Handler executes a run of a task and returns its result.
+type Handler func(ctx context.Context, run RunInfo) task.Result
+
+// Middleware wraps the handler executing the runs of tasks. A middleware can act before and after
+// calling next, change the context or the result, or return a result without calling next at all.
+type Middleware func(next Handler) Handler
+
+// ScheduledHook is called when a task is scheduled.
+type ScheduledHook func(info TaskInfo)
+
+// StartHook is called when a run of a task starts executing.
+type StartHook func(run RunInfo)
+
+// MisfireHook is called when a run of a task is dispatched later than its misfire grace allows,
+// along with the misfire policy which is applied to it.
+type MisfireHook func(run RunInfo, policy task.MisfirePolicy)
+
+// HookPanicError is reported to the error handler when a hook or a handler panics.
+type HookPanicError struct {
+	// Hook names the hook or handler which panicked.
+	Hook string
+	// Value is the value the hook panicked with.
+	Value interface{}
+	// Stack is the stack trace of the goroutine at the time of the panic.
+	Stack []byte
+}
+
+func (err *HookPanicError) Error() string {
+	return fmt.Sprintf("%s panicked: %v", err.Hook, err.Value)
+}
+
+// hooks holds the middlewares and the lifecycle hooks registered on the scheduler.
+type hooks struct {
+	middlewares []Middleware
+	scheduled   []ScheduledHook
+	start       []StartHook
+	success     []ResultHandler
+	failure     []ResultHandler
+	cancel      []ResultHandler
+	misfire     []MisfireHook
+}
+
+// Use adds middlewares around the execution of every run of the tasks, including manual runs.
+// The first middleware added is the outermost one. Middlewares see the context of the run, which is
+// cancelled when the task's timeout expires, and are called from the goroutine which executes the run.
+func (scheduler *Scheduler) Use(middlewares ...Middleware) {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	scheduler.hooks.middlewares = append(scheduler.hooks.middlewares, middlewares...)
+}
+
+// OnScheduled registers a hook which is called with a snapshot of every task once it's scheduled.
+func (scheduler *Scheduler) OnScheduled(hook ScheduledHook) {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	scheduler.hooks.scheduled = append(scheduler.hooks.scheduled, hook)
+}
+
+// OnStart registers a hook which is called before every run of a task is handed over to the middlewares.
+func (scheduler *Scheduler) OnStart(hook StartHook) {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	scheduler.hooks.start = append(scheduler.hooks.start, hook)
+}
+
+// OnSuccess registers a handler which is called after every run of a task which succeeded.
+func (scheduler *Scheduler) OnSuccess(handler ResultHandler) {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	scheduler.hooks.success = append(scheduler.hooks.success, handler)
+}
+
+// OnFailure registers a handler which is called after every run of a task which failed, panicked or timed out.
+func (scheduler *Scheduler) OnFailure(handler ResultHandler) {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	scheduler.hooks.failure = append(scheduler.hooks.failure, handler)
+}
+
+// OnCancel registers a handler which is called after every run of a task which was cancelled,
+// because the task was cancelled, replaced or superseded by a newer run, or the scheduler stopped.
+func (scheduler *Scheduler) OnCancel(handler ResultHandler) {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	scheduler.hooks.cancel = append(scheduler.hooks.cancel, handler)
+}
+
+// OnMisfire registers a hook which is called when a run of a task misfired. The hook is called from
+// a separate goroutine, by the time it's called the run may have been executed already.
+func (scheduler *Scheduler) OnMisfire(hook MisfireHook) {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	scheduler.hooks.misfire = append(scheduler.hooks.misfire, hook)
+}
+
+// runInfo describes the execution to the middlewares and the hooks.
+func (exec *execution) runInfo() RunInfo {
+	return RunInfo{
+		TaskID:      exec.taskID,
+		FuncName:    exec.task.Func.Name,
+		Params:      exec.task.Clone().Params,
+		ScheduledAt: exec.scheduledAt,
+		Attempt:     exec.attempt,
+		Manual:      exec.manual,
+	}
+}
+
+// handle runs the execution through the middlewares, the innermost handler calls the task's function.
+// Panics raised by the middlewares are recovered like the ones raised by the function.
+func (exec *execution) handle(ctx context.Context, middlewares []Middleware, run RunInfo) (result task.Result) {
+	defer func() {
+		if value := recover(); value != nil {
+			result = task.Result{
+				Err: &task.PanicError{
+					Value: value,
+					Stack: debug.Stack(),
+				},
+			}
+		}
+	}()
+
+	handler := Handler(func(ctx context.Context, run RunInfo) task.Result {
+		return exec.task.CallWithContext(ctx)
+	})
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler(ctx, run)
+}
+
+// callHook calls a hook or a handler supplied by the user. A panic raised by the hook is recovered
+// and reported to the error handler, so that it can't bring down the process.
+func (scheduler *Scheduler) callHook(name string, hook func()) {
+	defer func() {
+		if value := recover(); value != nil {
+			scheduler.reportError(&HookPanicError{
+				Hook:  name,
+				Value: value,
+				Stack: debug.Stack(),
+			})
+		}
+	}()
+	hook()
+}
+
+// reportResult calls the handlers of the result of an execution. The outcome hooks are called
+// unless the run was skipped or dropped, which are only reported to the result handlers.
+func (scheduler *Scheduler) reportResult(result task.Result, handlers []ResultHandler, hooks hooks) {
+	var outcomeHandlers []ResultHandler
+	name := ""
+	switch outcomeOf(result) {
+	case OutcomeSucceeded:
+		outcomeHandlers, name = hooks.success, "OnSuccess"
+	case OutcomeCancelled:
+		outcomeHandlers, name = hooks.cancel, "OnCancel"
+	case OutcomeSkipped, OutcomeDropped:
+	default:
+		outcomeHandlers, name = hooks.failure, "OnFailure"
+	}
+	for _, handler := range outcomeHandlers {
+		scheduler.callHook(name+" hook", func() { handler(result) })
+	}
+	for _, handler := range handlers {
+		scheduler.callHook("OnResult handler", func() { handler(result) })
+	}
+}
+
+// reportMisfire calls the misfire hooks from a separate goroutine.
+// It must be called with scheduler.mu held.
+func (scheduler *Scheduler) reportMisfire(taskID task.ID, t *task.Task, scheduledAt time.Time, policy task.MisfirePolicy) {
+	hooks := scheduler.hooks.misfire
+	if len(hooks) == 0 {
+		return
+	}
+	run := RunInfo{
+		TaskID:      taskID,
+		FuncName:    t.Func.Name,
+		Params:      t.Clone().Params,
+		ScheduledAt: scheduledAt,
+		Attempt:     t.Attempt + 1,
+	}
+	go func() {
+		for _, hook := range hooks {
+			scheduler.callHook("OnMisfire hook", func() { hook(run, policy) })
+		}
+	}()
+}
@@ -0,0 +1,40 @@
+package scheduler
+
+import (
+	"errors"
+
+	"github.com/rakanalh/scheduler/task"
+)
+
+// ErrTaskExists is returned when a task is scheduled with the ID of a scheduled task and
+// the conflict mode is ConflictReject.
+var ErrTaskExists = errors.New("Task already exists")
+
+// ConflictMode defines what happens when a task is scheduled with the ID of a scheduled task.
+type ConflictMode int
+
+const (
+	// ConflictReplace replaces the scheduled task with the new one, the running executions
+	// of the scheduled task are cancelled.
+	ConflictReplace ConflictMode = iota
+	// ConflictReject keeps the scheduled task, scheduling the new one fails with ErrTaskExists.
+	ConflictReject
+	// ConflictIgnore keeps the scheduled task and returns its ID, the new one is discarded.
+	ConflictIgnore
+)
+
+// WithConflictMode sets what happens when a task is scheduled with the ID of a scheduled task.
+// Scheduled tasks are replaced by default.
+func WithConflictMode(mode ConflictMode) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.conflictMode = mode
+	}
+}
+
+// sameDefinition reports whether both tasks call the same function with the same params on the
+// same schedule, regardless of their IDs.
+func sameDefinition(a, b *task.Task) bool {
+	a, b = a.Clone(), b.Clone()
+	a.ID, b.ID = "", ""
+	return a.Hash() == b.Hash()
+}
@@ -0,0 +1,103 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+
+	"github.com/rakanalh/scheduler/task"
+)
+
+// TaskStatus describes what a scheduled task is doing.
+type TaskStatus string
+
+const (
+	// StatusScheduled is the status of tasks waiting for their next run.
+	StatusScheduled TaskStatus = "scheduled"
+	// StatusRunning is the status of tasks which have at least one execution in progress.
+	StatusRunning TaskStatus = "running"
+	// StatusRetrying is the status of tasks waiting to retry a failed run.
+	StatusRetrying TaskStatus = "retrying"
+	// StatusPaused is the status of tasks which are paused, or of every task while the scheduler is paused.
+	StatusPaused TaskStatus = "paused"
+)
+
+// TaskInfo is a snapshot of a scheduled task, changing it doesn't affect the task.
+type TaskInfo struct {
+	ID       task.ID
+	FuncName string
+	Params   []task.Param
+	// Schedule holds the recurrence of the task along with its last and next runs.
+	task.Schedule
+	Status TaskStatus
+	// RunCount is the number of executions of the task started since the scheduler was created.
+	RunCount int
+}
+
+// Tasks returns a snapshot of every scheduled task, ordered by their next run.
+func (scheduler *Scheduler) Tasks() []TaskInfo {
+	return scheduler.snapshot(func(TaskInfo) bool {
+		return true
+	})
+}
+
+// Task returns a snapshot of the scheduled task, or false if there is no such task.
+func (scheduler *Scheduler) Task(taskID task.ID) (TaskInfo, bool) {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	t, found := scheduler.tasks[taskID]
+	if !found {
+		return TaskInfo{}, false
+	}
+	return scheduler.taskInfo(taskID, t), true
+}
+
+// Upcoming returns a snapshot of the tasks whose next run is due within the window, ordered by their next run.
+// Tasks which are already due are included.
+func (scheduler *Scheduler) Upcoming(window time.Duration) []TaskInfo {
+	until := scheduler.clock.Now().Add(window)
+	return scheduler.snapshot(func(info TaskInfo) bool {
+		return !info.NextRun.After(until)
+	})
+}
+
+// snapshot returns the snapshots of the tasks selected by the filter, ordered by their next run.
+func (scheduler *Scheduler) snapshot(filter func(TaskInfo) bool) []TaskInfo {
+	scheduler.mu.Lock()
+	infos := make([]TaskInfo, 0, len(scheduler.tasks))
+	for taskID, t := range scheduler.tasks {
+		if info := scheduler.taskInfo(taskID, t); filter(info) {
+			infos = append(infos, info)
+		}
+	}
+	scheduler.mu.Unlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		if !infos[i].NextRun.Equal(infos[j].NextRun) {
+			return infos[i].NextRun.Before(infos[j].NextRun)
+		}
+		return infos[i].ID < infos[j].ID
+	})
+	return infos
+}
+
+// taskInfo returns the snapshot of the task. It must be called with scheduler.mu held.
+func (scheduler *Scheduler) taskInfo(taskID task.ID, t *task.Task) TaskInfo {
+	clone := t.Clone()
+	status := StatusScheduled
+	if clone.Paused || scheduler.paused {
+		status = StatusPaused
+	} else if len(scheduler.running[taskID]) > 0 {
+		status = StatusRunning
+	} else if clone.Attempt > 0 {
+		status = StatusRetrying
+	}
+	return TaskInfo{
+		ID:       taskID,
+		FuncName: clone.Func.Name,
+		Params:   clone.Params,
+		Schedule: clone.Schedule,
+		Status:   status,
+		RunCount: scheduler.runCounts[taskID],
+	}
+}
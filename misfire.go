@@ -0,0 +1,35 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/rakanalh/scheduler/task"
+)
+
+// DefaultMisfireGrace is how late a run can be dispatched before it's considered misfired.
+const DefaultMisfireGrace = time.Second
+
+// misfirePolicy returns the misfire policy of the task, which falls back to the scheduler's.
+func (scheduler *Scheduler) misfirePolicy(t *task.Task) task.MisfirePolicy {
+	if t.Misfire != task.MisfireDefault {
+		return t.Misfire
+	}
+	return scheduler.defaultMisfire
+}
+
+// catchUpExceeded counts a missed run which is executed to catch up and reports whether the task's
+// catch-up limit is exceeded, in which case the remaining missed runs are skipped.
+// It must be called with scheduler.mu held.
+func (scheduler *Scheduler) catchUpExceeded(taskID task.ID, t *task.Task) bool {
+	scheduler.caughtUp[taskID]++
+	return t.CatchUpLimit > 0 && scheduler.caughtUp[taskID] > t.CatchUpLimit
+}
+
+// isMisfire reports whether a run of the task due at nextRun and dispatched at now is late beyond its grace.
+func (scheduler *Scheduler) isMisfire(t *task.Task, nextRun, now time.Time) bool {
+	grace := scheduler.misfireGrace
+	if t.MisfireGrace > 0 {
+		grace = t.MisfireGrace
+	}
+	return now.Sub(nextRun) > grace
+}
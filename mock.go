@@ -17,6 +17,7 @@ const (
 	failOnFuncMeta
 	failOnEmptyParams
 	failOnEmptyListParams
+	failOnRemove
 )
 
 type storeMock struct {
@@ -87,7 +88,14 @@ func (s *storeMock) Fetch() ([]storage.TaskAttributes, error) {
 	return []storage.TaskAttributes{taskAttributes}, nil
 }
 
+func (s *storeMock) Update(task storage.TaskAttributes) error {
+	return nil
+}
+
 func (s *storeMock) Remove(task storage.TaskAttributes) error {
+	if s.Mode == failOnRemove {
+		return fmt.Errorf("Error")
+	}
 	return nil
 }
 func (s *storeMock) Close() error {
@@ -0,0 +1,92 @@
+package scheduler
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/rakanalh/scheduler/task"
+)
+
+// Reschedule moves the next run of a scheduled task to the given time, a time in the past makes the task
+// due right away. Recurring tasks resume their cadence from that run, a retry in progress is abandoned.
+func (scheduler *Scheduler) Reschedule(taskID task.ID, at time.Time) error {
+	return scheduler.modifyTask(taskID, func(t *task.Task) error {
+		t.NextRun = at
+		t.Attempt = 0
+		return nil
+	})
+}
+
+// UpdateInterval changes the interval of a task scheduled using RunEvery. The next run is one interval
+// after the last one, or after now when the task didn't run yet.
+func (scheduler *Scheduler) UpdateInterval(taskID task.ID, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("Interval must be positive, got %s", interval)
+	}
+	return scheduler.modifyTask(taskID, func(t *task.Task) error {
+		if !t.IsRecurring || t.Cron != nil {
+			return fmt.Errorf("Task %s isn't scheduled at an interval", taskID)
+		}
+		from := t.LastRun
+		if from.IsZero() {
+			from = scheduler.clock.Now()
+		}
+		t.Duration = interval
+		t.NextRun = t.Schedule.Next(from)
+		t.Attempt = 0
+		return nil
+	})
+}
+
+// UpdateParams changes the parameters passed to the task's function from its next run on.
+// The parameters must match the ones of the function.
+func (scheduler *Scheduler) UpdateParams(taskID task.ID, params ...task.Param) error {
+	return scheduler.modifyTask(taskID, func(t *task.Task) error {
+		paramTypes := t.Func.Params()
+		if len(params) != len(paramTypes) {
+			return fmt.Errorf("Function %s expects %d params, got %d", t.Func.Name, len(paramTypes), len(params))
+		}
+		for i, param := range params {
+			if param == nil || !reflect.TypeOf(param).AssignableTo(paramTypes[i]) {
+				return fmt.Errorf("Param %d of function %s must be a %s", i, t.Func.Name, paramTypes[i])
+			}
+		}
+		t.Params = params
+		return nil
+	})
+}
+
+// modifyTask applies the change to a copy of the scheduled task and stores it before updating the task,
+// so that the task is left untouched when the change can't be stored. The task keeps its ID even when
+// the change affects the attributes its ID is derived from, and is marked as modified so that the change
+// survives restarts.
+func (scheduler *Scheduler) modifyTask(taskID task.ID, change func(t *task.Task) error) error {
+	scheduler.mu.Lock()
+	defer scheduler.unlock()
+
+	t, found := scheduler.tasks[taskID]
+	if !found {
+		return fmt.Errorf("Task not found")
+	}
+
+	changed := t.Clone()
+	if err := change(changed); err != nil {
+		return err
+	}
+	if changed.Hash() != taskID {
+		changed.ID = taskID
+	}
+	changed.Modified = true
+	if scheduler.started {
+		if err := scheduler.writeStore((*storeBridge).Update, changed); err != nil {
+			return err
+		}
+	}
+
+	t.Update(changed)
+	delete(scheduler.caughtUp, taskID)
+	scheduler.queue.schedule(taskID, t)
+	scheduler.wake()
+	return nil
+}
@@ -0,0 +1,164 @@
+package scheduler
+
+import (
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/rakanalh/scheduler/storage"
+	"github.com/rakanalh/scheduler/task"
+)
+
+// Option configures a Scheduler, options are passed to New.
+type Option func(*Scheduler)
+
+// Logger is used by the scheduler to report what happens in the background, with the task ID
+// and the function as structured fields. A *slog.Logger satisfies it.
+type Logger = storage.Logger
+
+// ErrorHandler is called with the errors which happen in the background, such as
+// failures to update the task store after a task was executed. It's called once the
+// scheduler's lock is released, so it may use the scheduler.
+type ErrorHandler func(err error)
+
+// WithLogger sets the logger of the scheduler, the default slog logger is used by default.
+func WithLogger(logger Logger) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.logger = logger
+	}
+}
+
+// WithClock sets the clock used to schedule and dispatch tasks, the system clock is used by default.
+func WithClock(clock Clock) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.clock = clock
+	}
+}
+
+// WithTickResolution rounds the dispatcher's waits up to a multiple of the resolution, so that
+// tasks which are due close to each other are dispatched together. By default tasks are
+// dispatched as soon as they are due.
+func WithTickResolution(resolution time.Duration) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.tickResolution = resolution
+	}
+}
+
+// WithMisfirePolicy sets what happens to runs which are dispatched more than grace after they were due,
+// unless the task has its own policy. By default misfired runs are executed once, and the grace is
+// DefaultMisfireGrace.
+func WithMisfirePolicy(policy task.MisfirePolicy, grace time.Duration) Option {
+	return func(scheduler *Scheduler) {
+		if policy != task.MisfireDefault {
+			scheduler.defaultMisfire = policy
+		}
+		scheduler.misfireGrace = grace
+	}
+}
+
+// WithErrorHandler sets the handler of the errors which happen in the background.
+// By default they are logged.
+func WithErrorHandler(handler ErrorHandler) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.errorHandler = handler
+	}
+}
+
+// WithShutdownTimeout sets how long Run waits for the running tasks to return once it's stopping,
+// DefaultShutdownTimeout by default. Zero waits until they return, however long it takes.
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.shutdownTimeout = timeout
+	}
+}
+
+// WithSignalHandling makes the scheduler stop dispatching tasks when one of the signals is received,
+// which unblocks Wait and Run. SIGINT and SIGTERM are handled when no signals are provided.
+// Signals are not handled by default so that the application keeps control over them.
+func WithSignalHandling(signals ...os.Signal) Option {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+	return func(scheduler *Scheduler) {
+		scheduler.signals = signals
+	}
+}
+
+// TaskOption configures a single task. Options can be passed along with the function parameters
+// to RunAt, RunAfter, RunEvery and RunCron, they are applied to the task and never passed to the function.
+type TaskOption func(*task.Task)
+
+// WithID sets the ID of the task, which must be unique among the scheduled tasks. By default the ID
+// is derived from the function, its parameters and the schedule, so changing any of them changes the ID.
+// What happens when the ID is already scheduled depends on the scheduler's conflict mode.
+func WithID(id task.ID) TaskOption {
+	return func(t *task.Task) {
+		t.ID = id
+	}
+}
+
+// InLocation sets the time zone in which the task's recurrences are computed.
+// Cron expressions are matched against the wall clock of the location, and RunEvery durations
+// which are a multiple of 24 hours keep the same wall clock time across daylight saving time changes.
+func InLocation(location *time.Location) TaskOption {
+	return func(t *task.Task) {
+		t.Location = location
+	}
+}
+
+// WithRetry sets the policy used to retry the task when its function returns an error or panics.
+func WithRetry(policy task.RetryPolicy) TaskOption {
+	return func(t *task.Task) {
+		t.Retry = &policy
+	}
+}
+
+// WithTimeout limits the duration of every execution of the task. The context passed to
+// functions accepting a context.Context as their first parameter is cancelled once it expires.
+func WithTimeout(timeout time.Duration) TaskOption {
+	return func(t *task.Task) {
+		t.Timeout = timeout
+	}
+}
+
+// WithOverlapPolicy sets what happens when the task is due while a previous run of it is still executing.
+// Overlapping runs are allowed by default.
+func WithOverlapPolicy(policy task.OverlapPolicy) TaskOption {
+	return func(t *task.Task) {
+		t.Overlap = policy
+	}
+}
+
+// WithTaskMisfirePolicy sets what happens to runs of the task which are dispatched more than grace
+// after they were due. A zero grace keeps the scheduler's grace.
+func WithTaskMisfirePolicy(policy task.MisfirePolicy, grace time.Duration) TaskOption {
+	return func(t *task.Task) {
+		t.Misfire = policy
+		t.MisfireGrace = grace
+	}
+}
+
+// WithCatchUp executes every run of the task which was missed, for instance while the scheduler was
+// not running, one after the other and in order. At most limit missed runs are executed and the
+// remaining ones are skipped, zero means no limit. The time at which a run was due is available
+// to the function using ScheduledTime.
+func WithCatchUp(limit int) TaskOption {
+	return func(t *task.Task) {
+		t.Misfire = task.MisfireFireAllMissed
+		t.CatchUpLimit = limit
+	}
+}
+
+// splitParams separates the task options from the parameters which should be passed to the function.
+func splitParams(params []task.Param) ([]task.Param, []TaskOption) {
+	var funcParams []task.Param
+	var options []TaskOption
+	for _, param := range params {
+		if option, ok := param.(TaskOption); ok {
+			options = append(options, option)
+			continue
+		}
+		funcParams = append(funcParams, param)
+	}
+	return funcParams, options
+}
@@ -0,0 +1,77 @@
+package scheduler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/rakanalh/scheduler/task"
+)
+
+// ErrOverlap is the error of the result of runs skipped because the task was still running.
+var ErrOverlap = errors.New("Task is still running")
+
+// overlapped applies the task's overlap policy when its run due at scheduledAt is dispatched while a previous
+// run is still executing. It returns true when the run must not be executed now. It must be called with
+// scheduler.mu held.
+func (scheduler *Scheduler) overlapped(taskID task.ID, t *task.Task, scheduledAt, now time.Time) bool {
+	if len(scheduler.running[taskID]) == 0 {
+		return false
+	}
+
+	switch t.Overlap {
+	case task.OverlapSkip:
+		scheduler.skipRun(taskID, t, scheduledAt, now)
+		return true
+	case task.OverlapQueueOne:
+		if _, queued := scheduler.queuedRuns[taskID]; queued {
+			scheduler.skipRun(taskID, t, scheduledAt, now)
+		} else {
+			scheduler.queuedRuns[taskID] = scheduledAt
+		}
+		return true
+	case task.OverlapCancelPrevious:
+		for exec := range scheduler.running[taskID] {
+			exec.superseded = true
+			exec.cancel()
+		}
+	}
+	return false
+}
+
+// skipRun reports a run which was skipped because the task was still running.
+// It must be called with scheduler.mu held.
+func (scheduler *Scheduler) skipRun(taskID task.ID, t *task.Task, scheduledAt, now time.Time) {
+	result := task.Result{
+		TaskID:      taskID,
+		FuncName:    t.Func.Name,
+		Attempt:     t.Attempt + 1,
+		ScheduledAt: scheduledAt,
+		StartedAt:   now,
+		FinishedAt:  now,
+		Err:         ErrOverlap,
+	}
+	handlers := scheduler.resultHandlers
+	go func() {
+		scheduler.recordHistory(result)
+		scheduler.reportResult(result, handlers, hooks{})
+	}()
+}
+
+// startQueuedRun executes the run which waited for the previous runs of the task to finish, the run keeps
+// the time it was due at. It must be called with scheduler.mu held.
+func (scheduler *Scheduler) startQueuedRun(taskID task.ID) {
+	scheduledAt, queued := scheduler.queuedRuns[taskID]
+	if !queued || len(scheduler.running[taskID]) > 0 {
+		return
+	}
+	delete(scheduler.queuedRuns, taskID)
+
+	t, ok := scheduler.tasks[taskID]
+	if !ok || scheduler.stopped || t.Paused {
+		return
+	}
+	exec := scheduler.startExecution(taskID, t, scheduledAt)
+	if !scheduler.submit(exec) {
+		scheduler.dropExecution(exec, scheduler.clock.Now())
+	}
+}
@@ -0,0 +1,71 @@
+package scheduler
+
+import (
+	"fmt"
+
+	"github.com/rakanalh/scheduler/task"
+)
+
+// Pause stops dispatching the runs of the task until it's resumed, without removing it.
+// Executions which already started are not cancelled. The task stays paused after a restart.
+func (scheduler *Scheduler) Pause(taskID task.ID) error {
+	return scheduler.setPaused(taskID, true)
+}
+
+// Resume dispatches the runs of a paused task again. The runs which were missed while the task
+// was paused are handled according to its misfire policy, the same way as runs missed during a downtime.
+func (scheduler *Scheduler) Resume(taskID task.ID) error {
+	return scheduler.setPaused(taskID, false)
+}
+
+// PauseAll stops dispatching the runs of every task until ResumeAll is called, tasks can still be
+// scheduled in the meantime. Unlike Pause, it only lasts while the process runs.
+func (scheduler *Scheduler) PauseAll() {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	scheduler.paused = true
+}
+
+// ResumeAll dispatches the runs of the tasks again after PauseAll, the tasks paused using Pause stay paused.
+// The runs which were missed in the meantime are handled according to the misfire policy.
+func (scheduler *Scheduler) ResumeAll() {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	scheduler.paused = false
+	scheduler.wake()
+}
+
+// setPaused pauses or resumes the task and stores its paused flag.
+func (scheduler *Scheduler) setPaused(taskID task.ID, paused bool) error {
+	scheduler.mu.Lock()
+	defer scheduler.unlock()
+
+	t, found := scheduler.tasks[taskID]
+	if !found {
+		return fmt.Errorf("Task not found")
+	}
+	if t.Paused == paused {
+		return nil
+	}
+
+	t.Paused = paused
+	if scheduler.started {
+		if err := scheduler.writeStore((*storeBridge).Update, t); err != nil {
+			t.Paused = !paused
+			return err
+		}
+	}
+
+	if paused {
+		scheduler.queue.remove(taskID)
+		scheduler.unholdTask(taskID, t)
+		delete(scheduler.queuedRuns, taskID)
+	} else {
+		delete(scheduler.caughtUp, taskID)
+		scheduler.queue.schedule(taskID, t)
+		scheduler.wake()
+	}
+	return nil
+}
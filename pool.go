@@ -0,0 +1,160 @@
+package scheduler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/rakanalh/scheduler/task"
+)
+
+// DefaultDeferDelay is how long runs are postponed by QueueFullDefer when no delay is configured.
+const DefaultDeferDelay = time.Second
+
+// ErrQueueFull is the error of the result of runs dropped because the worker pool's queue was full.
+var ErrQueueFull = errors.New("Worker pool queue is full")
+
+// QueueFullPolicy defines what happens to a due run when all workers are busy and the queue is full.
+type QueueFullPolicy int
+
+const (
+	// QueueFullBlock keeps the run in the scheduler until a worker is available.
+	// No other task is dispatched in the meantime.
+	QueueFullBlock QueueFullPolicy = iota
+	// QueueFullDrop drops the run, it's reported as a failed result whose error is ErrQueueFull.
+	QueueFullDrop
+	// QueueFullDefer postpones the run by the pool's DeferDelay, other due tasks keep being dispatched.
+	QueueFullDefer
+)
+
+// WorkerPool configures the pool of workers which execute the tasks.
+type WorkerPool struct {
+	// Size is the number of workers, and so the number of tasks executing at the same time.
+	Size int
+	// QueueSize is the number of runs which can wait for a worker once they are due.
+	QueueSize int
+	// QueueFull defines what happens to a due run when the queue is full.
+	QueueFull QueueFullPolicy
+	// DeferDelay is how long runs are postponed by QueueFullDefer, DefaultDeferDelay by default.
+	DeferDelay time.Duration
+}
+
+// WithWorkerPool executes tasks using a pool of workers rather than a goroutine per run.
+// The workers are started by New and stop once the scheduler is stopped.
+func WithWorkerPool(pool WorkerPool) Option {
+	return func(scheduler *Scheduler) {
+		if pool.Size <= 0 {
+			return
+		}
+		if pool.DeferDelay <= 0 {
+			pool.DeferDelay = DefaultDeferDelay
+		}
+		scheduler.pool = &pool
+	}
+}
+
+// WithWorkerPoolSize executes tasks using the given number of workers, due runs wait
+// in the scheduler until a worker is available.
+func WithWorkerPoolSize(size int) Option {
+	return WithWorkerPool(WorkerPool{Size: size})
+}
+
+// WithFunctionConcurrency limits the number of runs of the function with the given name which
+// execute at the same time, the name is the one returned by task.FuncName. Runs over the limit wait
+// until a run of the same function finished, without holding back the other tasks.
+func WithFunctionConcurrency(name string, limit int) Option {
+	return func(scheduler *Scheduler) {
+		scheduler.funcLimits[name] = limit
+	}
+}
+
+// startWorkers starts the workers of the pool, if the scheduler has one.
+func (scheduler *Scheduler) startWorkers() {
+	if scheduler.pool == nil {
+		return
+	}
+	// The pool's load is bounded by the size of the channel, sending a job never blocks
+	scheduler.jobs = make(chan *execution, scheduler.pool.Size+scheduler.pool.QueueSize)
+	for i := 0; i < scheduler.pool.Size; i++ {
+		go func(jobs <-chan *execution) {
+			for exec := range jobs {
+				scheduler.execute(exec)
+			}
+		}(scheduler.jobs)
+	}
+}
+
+// submit hands the execution over to a worker, or to a new goroutine when there is no pool.
+// It returns false when the pool's queue is full. It must be called with scheduler.mu held.
+func (scheduler *Scheduler) submit(exec *execution) bool {
+	if scheduler.jobs == nil {
+		go scheduler.execute(exec)
+		return true
+	}
+	if scheduler.poolLoad >= cap(scheduler.jobs) {
+		return false
+	}
+	scheduler.poolLoad++
+	exec.pooled = true
+	scheduler.jobs <- exec
+	return true
+}
+
+// dropExecution completes the execution without calling the task's function, its result's error is ErrQueueFull.
+// It must be called with scheduler.mu held.
+func (scheduler *Scheduler) dropExecution(exec *execution, now time.Time) {
+	scheduler.logger.Warn("Worker pool queue is full, the run is dropped", "task", exec.taskID, "func", exec.task.Func.Name)
+	go scheduler.complete(exec, task.Result{
+		FuncName:   exec.task.Func.Name,
+		StartedAt:  now,
+		FinishedAt: now,
+		Err:        ErrQueueFull,
+	})
+}
+
+// atFuncLimit reports whether the task's function already runs as many times as its limit allows.
+// It must be called with scheduler.mu held.
+func (scheduler *Scheduler) atFuncLimit(t *task.Task) bool {
+	limit, ok := scheduler.funcLimits[t.Func.Name]
+	return ok && scheduler.funcRunning[t.Func.Name] >= limit
+}
+
+// holdForFunc keeps a due run aside until a run of the same function finished.
+// It must be called with scheduler.mu held.
+func (scheduler *Scheduler) holdForFunc(item *queueItem) {
+	name := item.task.Func.Name
+	scheduler.funcWaiting[name] = append(scheduler.funcWaiting[name], item)
+}
+
+// releaseForFunc queues again the oldest run waiting for the function, once it's under its limit.
+// It must be called with scheduler.mu held.
+func (scheduler *Scheduler) releaseForFunc(name string) {
+	waiting := scheduler.funcWaiting[name]
+	if len(waiting) == 0 || scheduler.funcRunning[name] >= scheduler.funcLimits[name] {
+		return
+	}
+	item := waiting[0]
+	if len(waiting) == 1 {
+		delete(scheduler.funcWaiting, name)
+	} else {
+		scheduler.funcWaiting[name] = waiting[1:]
+	}
+	scheduler.queue.scheduleAt(item.id, item.task, item.nextRun)
+	scheduler.wake()
+}
+
+// unholdTask forgets the runs of the task which wait for their function.
+// It must be called with scheduler.mu held.
+func (scheduler *Scheduler) unholdTask(taskID task.ID, t *task.Task) {
+	name := t.Func.Name
+	waiting := scheduler.funcWaiting[name][:0]
+	for _, item := range scheduler.funcWaiting[name] {
+		if item.id != taskID {
+			waiting = append(waiting, item)
+		}
+	}
+	if len(waiting) == 0 {
+		delete(scheduler.funcWaiting, name)
+	} else {
+		scheduler.funcWaiting[name] = waiting
+	}
+}
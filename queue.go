@@ -0,0 +1,103 @@
+package scheduler
+
+import (
+	"container/heap"
+	"time"
+
+	"github.com/rakanalh/scheduler/task"
+)
+
+// queueItem is a task waiting in the taskQueue. The NextRun is copied when the item
+// is queued so that the heap order can't be broken by changes to the task itself.
+type queueItem struct {
+	id      task.ID
+	task    *task.Task
+	nextRun time.Time
+	index   int
+}
+
+// taskQueue is a min-heap of tasks ordered by their next run time.
+// It implements heap.Interface, use its schedule, remove and pop methods rather than the heap package directly.
+type taskQueue struct {
+	items []*queueItem
+	byID  map[task.ID]*queueItem
+}
+
+func newTaskQueue() *taskQueue {
+	return &taskQueue{
+		byID: make(map[task.ID]*queueItem),
+	}
+}
+
+func (queue *taskQueue) Len() int {
+	return len(queue.items)
+}
+
+func (queue *taskQueue) Less(i, j int) bool {
+	return queue.items[i].nextRun.Before(queue.items[j].nextRun)
+}
+
+func (queue *taskQueue) Swap(i, j int) {
+	queue.items[i], queue.items[j] = queue.items[j], queue.items[i]
+	queue.items[i].index = i
+	queue.items[j].index = j
+}
+
+// Push is used by the heap package, use schedule instead.
+func (queue *taskQueue) Push(x interface{}) {
+	item := x.(*queueItem)
+	item.index = len(queue.items)
+	queue.items = append(queue.items, item)
+	queue.byID[item.id] = item
+}
+
+// Pop is used by the heap package, use pop instead.
+func (queue *taskQueue) Pop() interface{} {
+	last := len(queue.items) - 1
+	item := queue.items[last]
+	queue.items[last] = nil
+	queue.items = queue.items[:last]
+	delete(queue.byID, item.id)
+	item.index = -1
+	return item
+}
+
+// schedule queues the task at its current NextRun, moving it if it's already queued.
+func (queue *taskQueue) schedule(id task.ID, t *task.Task) {
+	queue.scheduleAt(id, t, t.NextRun)
+}
+
+// scheduleAt queues the task at the provided time rather than its NextRun, moving it if it's already queued.
+func (queue *taskQueue) scheduleAt(id task.ID, t *task.Task, at time.Time) {
+	if item, ok := queue.byID[id]; ok {
+		item.task = t
+		item.nextRun = at
+		heap.Fix(queue, item.index)
+		return
+	}
+	heap.Push(queue, &queueItem{
+		id:      id,
+		task:    t,
+		nextRun: at,
+	})
+}
+
+// remove takes the task with the provided ID out of the queue.
+func (queue *taskQueue) remove(id task.ID) {
+	if item, ok := queue.byID[id]; ok {
+		heap.Remove(queue, item.index)
+	}
+}
+
+// peek returns the item which is due first without removing it, or nil if the queue is empty.
+func (queue *taskQueue) peek() *queueItem {
+	if len(queue.items) == 0 {
+		return nil
+	}
+	return queue.items[0]
+}
+
+// pop removes and returns the item which is due first.
+func (queue *taskQueue) pop() *queueItem {
+	return heap.Pop(queue).(*queueItem)
+}
@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rakanalh/scheduler/task"
+)
+
+func TestQueueOrder(t *testing.T) {
+	mock := task.CallbackMock{}
+	funcRegistry := task.NewFuncRegistry()
+	queue := newTaskQueue()
+
+	timeNow := time.Now()
+	offsets := []time.Duration{5 * time.Second, time.Second, 3 * time.Second, 2 * time.Second, 4 * time.Second}
+	for i, offset := range offsets {
+		queuedTask := newTask(funcRegistry, mock.CallWithArgs, "Test", i%2 == 0)
+		queuedTask.NextRun = timeNow.Add(offset)
+		queue.schedule(task.ID(offset.String()), queuedTask)
+	}
+
+	if queue.Len() != len(offsets) {
+		t.Error("All tasks should be queued")
+	}
+
+	for expected := time.Second; expected <= 5*time.Second; expected += time.Second {
+		item := queue.pop()
+		if item.nextRun != timeNow.Add(expected) {
+			t.Errorf("Expected task due after %s, got %s", expected, item.nextRun.Sub(timeNow))
+		}
+	}
+
+	if queue.peek() != nil {
+		t.Error("Queue should be empty")
+	}
+}
+
+func TestQueueRescheduleAndRemove(t *testing.T) {
+	mock := task.CallbackMock{}
+	funcRegistry := task.NewFuncRegistry()
+	queue := newTaskQueue()
+
+	timeNow := time.Now()
+	first := newTask(funcRegistry, mock.CallNoArgs)
+	first.NextRun = timeNow.Add(time.Second)
+	second := newTask(funcRegistry, mock.CallWithArgs, "Test", true)
+	second.NextRun = timeNow.Add(2 * time.Second)
+	queue.schedule("first", first)
+	queue.schedule("second", second)
+
+	// Scheduling an already queued task moves it
+	first.NextRun = timeNow.Add(3 * time.Second)
+	queue.schedule("first", first)
+	if queue.Len() != 2 || queue.peek().id != "second" {
+		t.Error("Rescheduled task should be moved behind the other task")
+	}
+
+	queue.remove("second")
+	queue.remove("unknown")
+	if queue.Len() != 1 || queue.peek().id != "first" {
+		t.Error("Removed task should no longer be queued")
+	}
+}
@@ -4,144 +4,409 @@
 package scheduler
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"os/signal"
-	"syscall"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/rakanalh/scheduler/storage"
 	"github.com/rakanalh/scheduler/task"
 )
 
+// ErrStopped is returned when starting the scheduler or triggering a task once the scheduler was stopped.
+var ErrStopped = errors.New("Scheduler is stopped")
+
+// DefaultShutdownTimeout is how long Run waits for the running tasks to return once it's stopping.
+const DefaultShutdownTimeout = 30 * time.Second
+
 // Scheduler is used to schedule tasks. It holds information about those tasks
-// including metadata such as argument types and schedule times
+// including metadata such as argument types and schedule times.
+// A Scheduler is safe for concurrent use by multiple goroutines.
 type Scheduler struct {
-	funcRegistry *task.FuncRegistry
-	stopChan     chan bool
-	tasks        map[task.ID]*task.Task
-	taskStore    storeBridge
+	// mu guards tasks, queue, running, results, handlers, the lifecycle flags and the function registry pointers.
+	mu             sync.Mutex
+	ctx            context.Context
+	cancel         context.CancelFunc
+	funcRegistry   *task.FuncRegistry
+	started        bool
+	stopped        bool
+	stopOnce       sync.Once
+	stopChan       chan struct{}
+	doneChan       chan struct{}
+	closeOnce      sync.Once
+	closeErr       error
+	wakeChan       chan struct{}
+	inFlight       sync.WaitGroup
+	tasks          map[task.ID]*task.Task
+	queue          *taskQueue
+	running        map[task.ID]map[*execution]struct{}
+	taskStore      storeBridge
+	results        map[task.ID]task.Result
+	resultHandlers []ResultHandler
+	panicHandler   PanicHandler
+	hooks          hooks
+	jobs           chan *execution
+	poolLoad       int
+	poolBlocked    bool
+	paused         bool
+	funcRunning    map[string]int
+	funcWaiting    map[string][]*queueItem
+	queuedRuns     map[task.ID]time.Time
+	caughtUp       map[task.ID]int
+	runCounts      map[task.ID]int
+	lastPrune      time.Time
+	// errs are the errors which happened while mu was held, they are reported once it's released.
+	errs []error
+	// writes are the changes of the stored tasks queued while mu was held, they are applied in order
+	// once it's released. storeMu is held while applying them and guards the store's closed flag along with mu.
+	writes   []storeWrite
+	writesMu sync.Mutex
+	storeMu  sync.Mutex
+
+	// Configuration set by the options passed to New.
+	signals        []os.Signal
+	logger         Logger
+	clock          Clock
+	tickResolution time.Duration
+	pool           *WorkerPool
+	funcLimits     map[string]int
+	defaultMisfire task.MisfirePolicy
+	misfireGrace   time.Duration
+	errorHandler   ErrorHandler
+	// shutdownTimeout bounds how long Run waits for the running tasks, zero waits until they return.
+	shutdownTimeout time.Duration
+	conflictMode    ConflictMode
+	history         storage.HistoryStore
+	// historyRetention is how long history entries are kept, zero keeps them forever.
+	historyRetention time.Duration
+	nodeID           string
 }
 
-// New will return a new instance of the Scheduler struct.
-func New(store storage.TaskStore) Scheduler {
+// New will return a new instance of the Scheduler struct, configured by the provided options.
+func New(store storage.TaskStore, options ...Option) *Scheduler {
 	funcRegistry := task.NewFuncRegistry()
-	return Scheduler{
-		funcRegistry: funcRegistry,
-		stopChan:     make(chan bool),
-		tasks:        make(map[task.ID]*task.Task),
+	ctx, cancel := context.WithCancel(context.Background())
+	scheduler := &Scheduler{
+		ctx:             ctx,
+		cancel:          cancel,
+		funcRegistry:    funcRegistry,
+		stopChan:        make(chan struct{}),
+		doneChan:        make(chan struct{}),
+		wakeChan:        make(chan struct{}, 1),
+		tasks:           make(map[task.ID]*task.Task),
+		queue:           newTaskQueue(),
+		running:         make(map[task.ID]map[*execution]struct{}),
+		results:         make(map[task.ID]task.Result),
+		funcRunning:     make(map[string]int),
+		funcWaiting:     make(map[string][]*queueItem),
+		queuedRuns:      make(map[task.ID]time.Time),
+		caughtUp:        make(map[task.ID]int),
+		runCounts:       make(map[task.ID]int),
+		funcLimits:      make(map[string]int),
+		logger:          slog.Default(),
+		clock:           realClock{},
+		misfireGrace:    DefaultMisfireGrace,
+		shutdownTimeout: DefaultShutdownTimeout,
+		defaultMisfire:  task.MisfireFireOnceNow,
 		taskStore: storeBridge{
 			store:        store,
 			funcRegistry: funcRegistry,
 		},
 	}
+	scheduler.nodeID, _ = os.Hostname()
+	scheduler.panicHandler = scheduler.logPanic
+	scheduler.errorHandler = scheduler.logError
+	for _, option := range options {
+		option(scheduler)
+	}
+	scheduler.startWorkers()
+	return scheduler
 }
 
 // RunAt will schedule function to be executed once at the given time.
 func (scheduler *Scheduler) RunAt(time time.Time, function task.Function, params ...task.Param) (task.ID, error) {
-	funcMeta, err := scheduler.funcRegistry.Add(function)
+	task, err := scheduler.createTask(function, params)
 	if err != nil {
 		return "", err
 	}
 
-	task := task.New(funcMeta, params)
-
 	task.NextRun = time
 
-	scheduler.registerTask(task)
-	return task.Hash(), nil
+	return scheduler.registerTask(task)
 }
 
 // RunAfter executes function once after a specific duration has elapsed.
 func (scheduler *Scheduler) RunAfter(duration time.Duration, function task.Function, params ...task.Param) (task.ID, error) {
-	return scheduler.RunAt(time.Now().Add(duration), function, params...)
+	return scheduler.RunAt(scheduler.clock.Now().Add(duration), function, params...)
 }
 
 // RunEvery will schedule function to be executed every time the duration has elapsed.
 func (scheduler *Scheduler) RunEvery(duration time.Duration, function task.Function, params ...task.Param) (task.ID, error) {
-	funcMeta, err := scheduler.funcRegistry.Add(function)
+	task, err := scheduler.createTask(function, params)
 	if err != nil {
 		return "", err
 	}
 
-	task := task.New(funcMeta, params)
-
 	task.IsRecurring = true
 	task.Duration = duration
-	task.NextRun = time.Now().Add(duration)
+	task.NextRun = task.Schedule.Next(scheduler.clock.Now())
 
-	scheduler.registerTask(task)
-	return task.Hash(), nil
+	return scheduler.registerTask(task)
+}
+
+// RunCron will schedule function to be executed whenever the cron expression matches.
+// Both 5-field and 6-field (with seconds) expressions are accepted, along with macros such as @hourly and @daily.
+func (scheduler *Scheduler) RunCron(expression string, function task.Function, params ...task.Param) (task.ID, error) {
+	cron, err := task.ParseCron(expression)
+	if err != nil {
+		return "", err
+	}
+
+	task, err := scheduler.createTask(function, params)
+	if err != nil {
+		return "", err
+	}
+
+	task.IsRecurring = true
+	task.Cron = cron
+	task.NextRun = task.Schedule.Next(scheduler.clock.Now())
+	if task.NextRun.IsZero() {
+		return "", fmt.Errorf("Cron expression %s never matches", expression)
+	}
+
+	return scheduler.registerTask(task)
 }
 
 // Start will run the scheduler's timer and will trigger the execution
-// of tasks depending on their schedule.
+// of tasks depending on their schedule. Start returns once the timer runs,
+// use Run to block until the scheduler is stopped.
 func (scheduler *Scheduler) Start() error {
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
 	// Populate tasks from storage
+	scheduler.mu.Lock()
+	if scheduler.stopped {
+		scheduler.unlock()
+		return ErrStopped
+	}
+	if scheduler.started {
+		scheduler.unlock()
+		return fmt.Errorf("Scheduler is already started")
+	}
 	if err := scheduler.populateTasks(); err != nil {
+		scheduler.unlock()
 		return err
 	}
 	if err := scheduler.persistRegisteredTasks(); err != nil {
+		scheduler.unlock()
 		return err
 	}
+	scheduler.started = true
+	scheduler.unlock()
 	scheduler.runPending()
 
+	// A nil channel never receives, signals are only handled when enabled using WithSignalHandling
+	var sigChan chan os.Signal
+	if len(scheduler.signals) > 0 {
+		sigChan = make(chan os.Signal, 1)
+		signal.Notify(sigChan, scheduler.signals...)
+	}
+
 	go func() {
-		ticker := time.NewTicker(1 * time.Second)
+		// The timer sleeps until the first task in the queue is due, it's
+		// re-armed after every dispatch and whenever the queue changes.
+		timer := scheduler.clock.NewTimer(0)
 		for {
 			select {
-			case <-ticker.C:
+			case <-timer.C():
 				scheduler.runPending()
+			case <-scheduler.wakeChan:
 			case <-sigChan:
-				scheduler.stopChan <- true
+				scheduler.halt()
 			case <-scheduler.stopChan:
-				close(scheduler.stopChan)
+				timer.Stop()
+				if sigChan != nil {
+					signal.Stop(sigChan)
+				}
+				close(scheduler.doneChan)
+				return
 			}
+			scheduler.resetTimer(timer)
 		}
 	}()
 
 	return nil
 }
 
-// Stop will put the scheduler to halt
+// Run starts the scheduler and blocks until ctx is done or the scheduler is stopped.
+// When ctx is done, the context of running tasks is cancelled and the scheduler is shut
+// down once they returned. When the scheduler is stopped by a signal, running tasks are
+// given until ctx is done to return. In both cases Run waits at most the shutdown timeout
+// set by WithShutdownTimeout for them. The returned error is the one of Start or Shutdown.
+func (scheduler *Scheduler) Run(ctx context.Context) error {
+	if err := scheduler.Start(); err != nil {
+		return err
+	}
+
+	parent := ctx
+	select {
+	case <-ctx.Done():
+		scheduler.cancel()
+		parent = context.Background()
+	case <-scheduler.doneChan:
+	}
+	shutdownCtx, cancel := context.WithCancel(parent)
+	if scheduler.shutdownTimeout > 0 {
+		shutdownCtx, cancel = context.WithTimeout(parent, scheduler.shutdownTimeout)
+	}
+	defer cancel()
+	return scheduler.Shutdown(shutdownCtx)
+}
+
+// Stop will put the scheduler to halt.
+// The context passed to running tasks is cancelled and the store is closed without
+// waiting for them to return, use Shutdown to stop gracefully.
 func (scheduler *Scheduler) Stop() {
-	scheduler.taskStore.store.Close()
-	scheduler.stopChan <- true
+	scheduler.cancel()
+	scheduler.halt()
+	_ = scheduler.closeStore()
+}
+
+// Shutdown stops dispatching tasks and waits for the running executions to return
+// before persisting the state of the scheduled tasks and closing the store.
+// If ctx is done before the executions returned, their context is cancelled, the store is
+// closed anyway and the returned error lists the tasks which were still running.
+func (scheduler *Scheduler) Shutdown(ctx context.Context) error {
+	scheduler.halt()
+
+	drained := make(chan struct{})
+	go func() {
+		scheduler.inFlight.Wait()
+		close(drained)
+	}()
+
+	var shutdownErr error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		scheduler.mu.Lock()
+		var running []string
+		for taskID := range scheduler.running {
+			running = append(running, string(taskID))
+		}
+		scheduler.mu.Unlock()
+		sort.Strings(running)
+		shutdownErr = fmt.Errorf("Shutdown interrupted with running tasks %s: %v",
+			strings.Join(running, ", "), ctx.Err())
+	}
+	scheduler.cancel()
+
+	scheduler.mu.Lock()
+	for _, task := range scheduler.tasks {
+		if scheduler.taskStore.closed {
+			// The scheduler was already stopped
+			break
+		}
+		if err := scheduler.writeStore((*storeBridge).Update, task); err != nil && shutdownErr == nil {
+			shutdownErr = err
+		}
+	}
+	scheduler.mu.Unlock()
+
+	if err := scheduler.closeStore(); err != nil && shutdownErr == nil {
+		shutdownErr = err
+	}
+	return shutdownErr
 }
 
 // Wait is a convenience function for blocking until the scheduler is stopped.
 func (scheduler *Scheduler) Wait() {
-	<-scheduler.stopChan
+	<-scheduler.doneChan
+}
+
+// halt stops the dispatching of tasks. Executions which are already running are left untouched.
+func (scheduler *Scheduler) halt() {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	scheduler.stopped = true
+	scheduler.stopOnce.Do(func() {
+		if scheduler.jobs != nil {
+			// Workers execute the runs which are already queued and return
+			close(scheduler.jobs)
+		}
+		close(scheduler.stopChan)
+		if !scheduler.started {
+			// There is no dispatcher to acknowledge the stop
+			close(scheduler.doneChan)
+		}
+	})
+}
+
+// closeStore closes the task store once, executions finishing afterwards don't update it anymore.
+func (scheduler *Scheduler) closeStore() error {
+	scheduler.closeOnce.Do(func() {
+		scheduler.mu.Lock()
+		defer scheduler.unlock()
+
+		scheduler.closeErr = scheduler.waitStore(func(sb *storeBridge) error {
+			sb.closed = true
+			return sb.store.Close()
+		})
+	})
+	return scheduler.closeErr
 }
 
 // Cancel is used to cancel the planned execution of a specific task using it's ID.
-// The ID is returned when the task was scheduled using RunAt, RunAfter or RunEvery
+// The ID is returned when the task was scheduled using RunAt, RunAfter or RunEvery.
+// The context passed to running executions of the task is cancelled.
 func (scheduler *Scheduler) Cancel(taskID task.ID) error {
+	scheduler.mu.Lock()
+	defer scheduler.unlock()
+
 	task, found := scheduler.tasks[taskID]
 	if !found {
 		return fmt.Errorf("Task not found")
 	}
 
-	_ = scheduler.taskStore.Remove(task)
-	delete(scheduler.tasks, taskID)
+	scheduler.removeTask(taskID, task)
+	scheduler.wake()
 	return nil
 }
 
 // Clear will cancel the execution and clear all registered tasks.
 func (scheduler *Scheduler) Clear() {
+	scheduler.mu.Lock()
+	defer scheduler.unlock()
+
 	for taskID, currentTask := range scheduler.tasks {
-		_ = scheduler.taskStore.Remove(currentTask)
+		scheduler.queueStore((*storeBridge).Remove, currentTask)
 		delete(scheduler.tasks, taskID)
+		scheduler.cancelRunning(taskID)
 	}
+	scheduler.results = make(map[task.ID]task.Result)
+	scheduler.queue = newTaskQueue()
+	scheduler.funcWaiting = make(map[string][]*queueItem)
+	scheduler.queuedRuns = make(map[task.ID]time.Time)
+	scheduler.caughtUp = make(map[task.ID]int)
+	scheduler.runCounts = make(map[task.ID]int)
 	scheduler.funcRegistry = task.NewFuncRegistry()
+	scheduler.taskStore.funcRegistry = scheduler.funcRegistry
+	scheduler.wake()
 }
 
+// populateTasks must be called with scheduler.mu held.
 func (scheduler *Scheduler) populateTasks() error {
-	tasks, err := scheduler.taskStore.Fetch()
+	var tasks []*task.Task
+	err := scheduler.waitStore(func(sb *storeBridge) (err error) {
+		tasks, err = sb.Fetch()
+		return err
+	})
 	if err != nil {
 		return err
 	}
@@ -150,8 +415,9 @@ func (scheduler *Scheduler) populateTasks() error {
 		// If we can't find the function, it's been changed/removed by user
 		exists := scheduler.funcRegistry.Exists(dbTask.Func.Name)
 		if !exists {
-			log.Printf("%s was not found, it will be removed\n", dbTask.Func.Name)
-			_ = scheduler.taskStore.Remove(dbTask)
+			scheduler.logger.Warn("Function of a stored task was not found, the task will be removed",
+				"task", dbTask.Hash(), "func", dbTask.Func.Name, "store", scheduler.taskStore.name())
+			scheduler.queueStore((*storeBridge).Remove, dbTask)
 			continue
 		}
 
@@ -159,35 +425,62 @@ func (scheduler *Scheduler) populateTasks() error {
 		// Otherwise, one of the attributes changed and therefore, the task instance should
 		// be added to the list of tasks to be executed with the stored params
 		registeredTask, ok := scheduler.tasks[dbTask.Hash()]
+		if ok && scheduler.conflictMode != ConflictReplace && registeredTask.ID != "" && !dbTask.Modified &&
+			!sameDefinition(registeredTask, dbTask) {
+			// The task was registered with the ID of a stored task which has other attributes,
+			// the stored task is kept unless the conflict mode replaces it
+			if scheduler.conflictMode == ConflictReject {
+				scheduler.queueError(fmt.Errorf("Task %s is stored with other attributes: %w", dbTask.Hash(), ErrTaskExists))
+			}
+			dbTask.Func, _ = scheduler.funcRegistry.Get(dbTask.Func.Name)
+			scheduler.tasks[dbTask.Hash()] = dbTask
+			continue
+		}
 		if !ok {
-			log.Printf("Detected a change in attributes of one of the instances of task %s, \n",
-				dbTask.Func.Name)
+			scheduler.logger.Info("Detected a change in the attributes of a stored task",
+				"task", dbTask.Hash(), "func", dbTask.Func.Name, "store", scheduler.taskStore.name())
 			dbTask.Func, _ = scheduler.funcRegistry.Get(dbTask.Func.Name)
 			registeredTask = dbTask
 			scheduler.tasks[dbTask.Hash()] = registeredTask
+		} else if dbTask.Modified {
+			// The task was changed through the scheduler, the stored schedule and params take
+			// precedence over the ones it was registered with
+			registeredTask.Update(dbTask)
+		} else if dbTask.Attempt > 0 {
+			// Resume the retries of the stored run
+			registeredTask.Attempt = dbTask.Attempt
+			registeredTask.LastRun = dbTask.LastRun
+			registeredTask.NextRun = dbTask.NextRun
+		} else if dbTask.IsRecurring {
+			// Resume the stored cadence, the runs which were missed during
+			// the downtime are handled according to the misfire policy
+			registeredTask.LastRun = dbTask.LastRun
+			registeredTask.NextRun = dbTask.NextRun
 		}
-
-		// Skip task which is not a recurring one and the NextRun has already passed
-		if !dbTask.IsRecurring && dbTask.NextRun.Before(time.Now()) {
-			// We might have a task instance which was executed already.
-			// In this case, delete it.
-			_ = scheduler.taskStore.Remove(dbTask)
-			delete(scheduler.tasks, dbTask.Hash())
-			continue
-		}
+		registeredTask.Paused = dbTask.Paused
 
 		// Duration may have changed for recurring tasks
-		if dbTask.IsRecurring && registeredTask.Duration != dbTask.Duration {
+		if dbTask.IsRecurring && !dbTask.Modified && registeredTask.Duration != dbTask.Duration {
 			// Reschedule NextRun based on dbTask.LastRun + registeredTask.Duration
 			registeredTask.NextRun = dbTask.LastRun.Add(registeredTask.Duration)
 		}
 	}
+
+	// Tasks were added, removed and rescheduled above, queue them again.
+	scheduler.queue = newTaskQueue()
+	for taskID, task := range scheduler.tasks {
+		if !task.Paused {
+			scheduler.queue.schedule(taskID, task)
+		}
+	}
 	return nil
 }
 
+// persistRegisteredTasks stores the registered tasks, replacing the stored ones with the same ID.
+// It must be called with scheduler.mu held.
 func (scheduler *Scheduler) persistRegisteredTasks() error {
 	for _, task := range scheduler.tasks {
-		err := scheduler.taskStore.Add(task)
+		err := scheduler.writeStore((*storeBridge).Update, task)
 		if err != nil {
 			return err
 		}
@@ -195,20 +488,235 @@ func (scheduler *Scheduler) persistRegisteredTasks() error {
 	return nil
 }
 
+// runPending dispatches every queued task which is due. Recurring tasks are rescheduled
+// before their function is called so that the queue is ordered by their next run, while
+// one-off tasks are kept until their execution finished and are removed by finishExecution.
+// Runs which are late beyond the misfire grace are handled according to the misfire policy.
 func (scheduler *Scheduler) runPending() {
-	for _, task := range scheduler.tasks {
-		if task.IsDue() {
-			go task.Run()
+	scheduler.mu.Lock()
+	defer scheduler.unlock()
 
-			if !task.IsRecurring {
-				_ = scheduler.taskStore.Remove(task)
-				delete(scheduler.tasks, task.Hash())
+	if scheduler.stopped || scheduler.paused {
+		return
+	}
+	now := scheduler.clock.Now()
+	for item := scheduler.queue.peek(); item != nil && !item.nextRun.After(now); item = scheduler.queue.peek() {
+		scheduler.queue.pop()
+		t := item.task
+		if t.Paused {
+			// Resume queues the task again
+			continue
+		}
+
+		if scheduler.atFuncLimit(t) {
+			scheduler.holdForFunc(item)
+			continue
+		}
+
+		misfire := task.MisfireDefault
+		if scheduler.isMisfire(t, item.nextRun, now) {
+			misfire = scheduler.misfirePolicy(t)
+		}
+		if misfire == task.MisfireFireAllMissed && scheduler.catchUpExceeded(item.id, t) {
+			misfire = task.MisfireSkipToNext
+		}
+		if misfire != task.MisfireDefault {
+			scheduler.reportMisfire(item.id, t, item.nextRun, misfire)
+		}
+		if misfire == task.MisfireDrop || (misfire == task.MisfireSkipToNext && !t.IsRecurring) {
+			scheduler.logger.Warn("Task misfired, it will be removed", "task", item.id, "func", t.Func.Name)
+			scheduler.removeTask(item.id, t)
+			continue
+		}
+		skip := misfire == task.MisfireSkipToNext || scheduler.overlapped(item.id, t, item.nextRun, now)
+		var execution *execution
+		if !skip {
+			execution = scheduler.startExecution(item.id, t, t.CurrentSchedule().NextRun)
+			if !scheduler.submit(execution) {
+				switch scheduler.pool.QueueFull {
+				case QueueFullDrop:
+					scheduler.dropExecution(execution, now)
+				case QueueFullDefer:
+					scheduler.discardExecution(execution)
+					scheduler.queue.scheduleAt(item.id, t, now.Add(scheduler.pool.DeferDelay))
+					continue
+				default:
+					// Wait for a worker to be available, complete wakes the dispatcher up
+					scheduler.discardExecution(execution)
+					scheduler.queue.scheduleAt(item.id, t, item.nextRun)
+					scheduler.poolBlocked = true
+					return
+				}
 			}
 		}
+		if misfire == task.MisfireFireAllMissed {
+			t.ScheduleNextRun()
+		} else {
+			// The runs which were missed while the scheduler was behind are skipped
+			t.ScheduleNextRunAfter(now)
+		}
+
+		schedule := t.CurrentSchedule()
+		if !schedule.IsRecurring {
+			continue
+		}
+		if schedule.NextRun.IsZero() {
+			scheduler.removeTask(item.id, t)
+			continue
+		}
+		// The schedule is persisted so that the task resumes its cadence after a restart
+		scheduler.queueStore((*storeBridge).Update, t)
+		if execution != nil && misfire == task.MisfireFireAllMissed && !schedule.NextRun.After(now) {
+			// Missed runs are executed one after the other, complete queues the next one
+			execution.catchUp = true
+			continue
+		}
+		delete(scheduler.caughtUp, item.id)
+		scheduler.queue.schedule(item.id, t)
+	}
+}
+
+// removeTask removes the task from the scheduler and the store.
+// It must be called with scheduler.mu held.
+func (scheduler *Scheduler) removeTask(taskID task.ID, task *task.Task) {
+	scheduler.queueStore((*storeBridge).Remove, task)
+	delete(scheduler.tasks, taskID)
+	delete(scheduler.results, taskID)
+	scheduler.queue.remove(taskID)
+	scheduler.unholdTask(taskID, task)
+	delete(scheduler.queuedRuns, taskID)
+	delete(scheduler.caughtUp, taskID)
+	delete(scheduler.runCounts, taskID)
+	scheduler.cancelRunning(taskID)
+}
+
+// resetTimer re-arms the timer to fire when the first queued task is due.
+// The timer is left stopped when the queue is empty.
+func (scheduler *Scheduler) resetTimer(timer Timer) {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	if !timer.Stop() {
+		select {
+		case <-timer.C():
+		default:
+		}
+	}
+	if scheduler.poolBlocked || scheduler.paused {
+		// The dispatcher is woken up once a worker is available or the scheduler is resumed
+		return
+	}
+	if next := scheduler.queue.peek(); next != nil {
+		delay := next.nextRun.Sub(scheduler.clock.Now())
+		if resolution := scheduler.tickResolution; resolution > 0 && delay > 0 && delay%resolution != 0 {
+			delay += resolution - delay%resolution
+		}
+		timer.Reset(delay)
+	}
+}
+
+// reportError passes errors which happen in the background to the error handler, its panics are logged.
+// It must not be called with scheduler.mu held, the handler may use the scheduler.
+func (scheduler *Scheduler) reportError(err error) {
+	if err == nil || err == errStoreClosed || scheduler.errorHandler == nil {
+		return
+	}
+	defer func() {
+		if value := recover(); value != nil {
+			scheduler.logger.Error("Error handler panicked", "error", err, "panic", value, "stack", string(debug.Stack()))
+		}
+	}()
+	scheduler.errorHandler(err)
+}
+
+// queueError keeps an error which happened while scheduler.mu is held, it's reported by unlock.
+// It must be called with scheduler.mu held.
+func (scheduler *Scheduler) queueError(err error) {
+	if err != nil && err != errStoreClosed {
+		scheduler.errs = append(scheduler.errs, err)
 	}
 }
 
-func (scheduler *Scheduler) registerTask(task *task.Task) {
-	_, _ = scheduler.funcRegistry.Add(task.Func)
-	scheduler.tasks[task.Hash()] = task
+// unlock releases scheduler.mu, applies the writes of the store queued while it was held and reports
+// the errors which happened meanwhile.
+func (scheduler *Scheduler) unlock() {
+	errs := scheduler.errs
+	scheduler.errs = nil
+	scheduler.mu.Unlock()
+
+	errs = append(errs, scheduler.flushStore()...)
+	for _, err := range errs {
+		scheduler.reportError(err)
+	}
+}
+
+// logError is the default error handler.
+func (scheduler *Scheduler) logError(err error) {
+	scheduler.logger.Error("Scheduler error", "error", err)
+}
+
+// wake notifies the dispatcher that the queue changed and its timer should be re-armed.
+func (scheduler *Scheduler) wake() {
+	select {
+	case scheduler.wakeChan <- struct{}{}:
+	default:
+	}
+}
+
+// createTask registers the function and returns a task calling it with params,
+// configured by the task options found among the params.
+func (scheduler *Scheduler) createTask(function task.Function, params []task.Param) (*task.Task, error) {
+	scheduler.mu.Lock()
+	funcRegistry := scheduler.funcRegistry
+	scheduler.mu.Unlock()
+
+	funcMeta, err := funcRegistry.Add(function)
+	if err != nil {
+		return nil, err
+	}
+
+	funcParams, options := splitParams(params)
+	task := task.New(funcMeta, funcParams)
+	for _, option := range options {
+		option(task)
+	}
+	return task, nil
+}
+
+// registerTask schedules the task, resolving a conflict with a scheduled task of the same ID
+// according to the scheduler's conflict mode. Tasks registered once the scheduler started are stored right away.
+func (scheduler *Scheduler) registerTask(t *task.Task) (task.ID, error) {
+	scheduler.mu.Lock()
+
+	taskID := t.Hash()
+	if existing, ok := scheduler.tasks[taskID]; ok {
+		switch scheduler.conflictMode {
+		case ConflictReject:
+			scheduler.unlock()
+			return "", ErrTaskExists
+		case ConflictIgnore:
+			scheduler.unlock()
+			return taskID, nil
+		default:
+			if scheduler.started {
+				scheduler.removeTask(taskID, existing)
+			}
+		}
+	}
+
+	_, _ = scheduler.funcRegistry.Add(t.Func)
+	scheduler.tasks[taskID] = t
+	scheduler.queue.schedule(taskID, t)
+	if scheduler.started {
+		scheduler.queueStore((*storeBridge).Add, t)
+	}
+	scheduler.wake()
+	info := scheduler.taskInfo(taskID, t)
+	hooks := scheduler.hooks.scheduled
+	scheduler.unlock()
+
+	for _, hook := range hooks {
+		scheduler.callHook("OnScheduled hook", func() { hook(info) })
+	}
+	return taskID, nil
 }
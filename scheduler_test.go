@@ -1,6 +1,14 @@
 package scheduler
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
 
@@ -65,6 +73,58 @@ func TestRunEvery(t *testing.T) {
 	}
 }
 
+func TestRunCron(t *testing.T) {
+	mock := task.CallbackMock{}
+	scheduler := New(storage.NewMemoryStorage())
+	taskID, err := scheduler.RunCron("*/5 * * * * *", mock.CallNoArgs)
+	if err != nil {
+		t.Error("Creating a cron task should succeed")
+	}
+
+	_, err = scheduler.RunCron("* * *", mock.CallNoArgs)
+	if err == nil {
+		t.Error("An invalid cron expression should have failed RunCron")
+	}
+
+	_, err = scheduler.RunCron("@hourly", "InvalidFunction")
+	if err == nil {
+		t.Error("InvalidFunction should have failed RunCron")
+	}
+
+	task := scheduler.tasks[taskID]
+	if !task.IsRecurring || task.Cron == nil {
+		t.Error("A cron task should be recurring")
+	}
+	if task.NextRun.Second()%5 != 0 || task.NextRun.Before(time.Now()) {
+		t.Error("The task's NextRun should match the cron expression")
+	}
+}
+
+func TestRunCronInLocation(t *testing.T) {
+	mock := task.CallbackMock{}
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("Time zone database is not available")
+	}
+
+	scheduler := New(storage.NewMemoryStorage())
+	taskID, err := scheduler.RunCron("0 9 * * *", mock.CallWithArgs, "Hello", true, InLocation(berlin))
+	if err != nil {
+		t.Error("Creating a cron task with a location should succeed")
+	}
+
+	task := scheduler.tasks[taskID]
+	if task.Location != berlin {
+		t.Error("The task's location should be set by the option")
+	}
+	if len(task.Params) != 2 {
+		t.Error("Task options should not be passed as function params")
+	}
+	if nextRun := task.NextRun.In(berlin); nextRun.Hour() != 9 || nextRun.Minute() != 0 {
+		t.Error("The task's NextRun should match the cron expression in the task's location")
+	}
+}
+
 func TestRunPending(t *testing.T) {
 	mock := task.CallbackMock{}
 	scheduler := New(storage.NewMemoryStorage())
@@ -80,9 +140,11 @@ func TestRunPending(t *testing.T) {
 	time.Sleep(100 * time.Millisecond)
 	mock.AssertExpectations(t)
 
+	scheduler.mu.Lock()
 	if len(scheduler.tasks) > 0 {
 		t.Error("Non-recurring task should be removed once executed")
 	}
+	scheduler.mu.Unlock()
 
 	// Test again with a recurring task
 	_, _ = scheduler.RunEvery(5, mock.CallNoArgs)
@@ -119,6 +181,1727 @@ func TestStart(t *testing.T) {
 	mock.AssertExpectations(t)
 }
 
+func TestOnResult(t *testing.T) {
+	mock := task.CallbackMock{}
+	mock.On("CallWithError", "Test").Return(errors.New("Failed"))
+
+	results := make(chan task.Result, 1)
+	scheduler := New(storage.NewMemoryStorage())
+	scheduler.OnResult(func(result task.Result) {
+		results <- result
+	})
+	taskID, _ := scheduler.RunEvery(time.Hour, mock.CallWithError, "Test")
+	scheduler.tasks[taskID].NextRun = time.Now()
+	scheduler.queue.schedule(taskID, scheduler.tasks[taskID])
+
+	scheduler.runPending()
+
+	select {
+	case result := <-results:
+		if result.TaskID != taskID || result.Err == nil || result.Err.Error() != "Failed" {
+			t.Error("Result handler should receive the task's error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Result handler was not called")
+	}
+
+	lastResult, ok := scheduler.LastResult(taskID)
+	if !ok || lastResult.Succeeded() {
+		t.Error("The failed execution should be recorded as the last result")
+	}
+
+	_ = scheduler.Cancel(taskID)
+	if _, ok := scheduler.LastResult(taskID); ok {
+		t.Error("Results should not be kept for cancelled tasks")
+	}
+}
+
+func TestOnPanic(t *testing.T) {
+	mock := task.CallbackMock{}
+	mock.On("CallWithPanic").Return()
+	mock.On("CallNoArgs").Return()
+
+	panics := make(chan *task.PanicError, 1)
+	scheduler := New(storage.NewMemoryStorage())
+	scheduler.OnPanic(func(result task.Result, panicErr *task.PanicError) {
+		panics <- panicErr
+	})
+	_, _ = scheduler.RunAt(time.Now(), mock.CallWithPanic)
+	_, _ = scheduler.RunAt(time.Now(), mock.CallNoArgs)
+
+	scheduler.runPending()
+
+	select {
+	case panicErr := <-panics:
+		if panicErr.Value != "CallWithPanic" || len(panicErr.Stack) == 0 {
+			t.Error("Panic handler should receive the panic value and stack trace")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Panic handler was not called")
+	}
+
+	// The other task should be unaffected by the panic
+	time.Sleep(100 * time.Millisecond)
+	mock.AssertExpectations(t)
+}
+
+func TestRetry(t *testing.T) {
+	mock := task.CallbackMock{}
+	mock.On("CallWithError", "Test").Return(errors.New("Failed")).Twice()
+	mock.On("CallWithError", "Test").Return(nil).Once()
+
+	results := make(chan task.Result, 3)
+	memStore := storage.NewMemoryStorage()
+	scheduler := New(memStore)
+	scheduler.OnResult(func(result task.Result) {
+		results <- result
+	})
+	taskID, _ := scheduler.RunAt(time.Now(), mock.CallWithError, "Test", WithRetry(task.RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     task.FixedBackoff,
+		Delay:       50 * time.Millisecond,
+	}))
+	scheduler.Start()
+	defer scheduler.Stop()
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		select {
+		case result := <-results:
+			if result.Attempt != attempt || result.Succeeded() != (attempt == 3) {
+				t.Errorf("Unexpected result for attempt %d: %+v", attempt, result)
+			}
+			if attempt == 1 {
+				storedTasks, _ := memStore.Fetch()
+				if len(storedTasks) != 1 || storedTasks[0].Attempt != "1" {
+					t.Error("Failed attempts should be persisted")
+				}
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Task was not retried")
+		}
+	}
+
+	mock.AssertExpectations(t)
+	if _, ok := scheduler.LastResult(taskID); ok {
+		t.Error("One-off task should be removed once it succeeded")
+	}
+	if storedTasks, _ := memStore.Fetch(); len(storedTasks) != 0 {
+		t.Error("One-off task should be removed from the store once it succeeded")
+	}
+}
+
+func TestRetriedOneOffIsRemovedFromStore(t *testing.T) {
+	memStore := storage.NewMemoryStorage()
+	scheduler := New(memStore)
+	taskID, _ := scheduler.RunAt(time.Now(), func() {}, WithRetry(task.RetryPolicy{MaxAttempts: 2}))
+	_ = scheduler.Start()
+	defer scheduler.Stop()
+
+	// The retry is due a few seconds after the failed run
+	scheduler.mu.Lock()
+	retried := scheduler.tasks[taskID]
+	retried.ScheduleRetry(retried.NextRun.Add(5 * time.Second))
+	_ = scheduler.taskStore.Update(retried)
+	scheduler.finishExecution(taskID, retried, task.Result{})
+	scheduler.unlock()
+
+	if storedTasks, _ := memStore.Fetch(); len(storedTasks) != 0 {
+		t.Errorf("The retried one-off task should be removed from the store, got %+v", storedTasks)
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	mock := task.CallbackMock{}
+	mock.On("CallWithError", "Test").Return(errors.New("Failed"))
+
+	results := make(chan task.Result, 3)
+	scheduler := New(storage.NewMemoryStorage())
+	scheduler.OnResult(func(result task.Result) {
+		results <- result
+	})
+	_, _ = scheduler.RunAt(time.Now(), mock.CallWithError, "Test", WithRetry(task.RetryPolicy{
+		MaxAttempts: 2,
+		Backoff:     task.ExponentialBackoff,
+		Delay:       10 * time.Millisecond,
+	}))
+	scheduler.Start()
+	defer scheduler.Stop()
+
+	time.Sleep(300 * time.Millisecond)
+	if len(results) != 2 {
+		t.Error("Task should be attempted MaxAttempts times, got", len(results))
+	}
+	mock.AssertNumberOfCalls(t, "CallWithError", 2)
+}
+
+func TestPopulateTasksResumesRetry(t *testing.T) {
+	mock := task.CallbackMock{}
+	memStore := storage.NewMemoryStorage()
+	retryAt := time.Now().Add(-time.Minute)
+
+	scheduler := New(memStore)
+	taskID, _ := scheduler.RunAt(time.Now().Add(-time.Hour), mock.CallWithError, "Test")
+	retryingTask := scheduler.tasks[taskID]
+	retryingTask.ScheduleRetry(retryAt)
+	_ = scheduler.taskStore.Add(retryingTask)
+
+	// A new process registers the same task again
+	scheduler = New(memStore)
+	_, _ = scheduler.RunAt(time.Now().Add(-time.Hour), mock.CallWithError, "Test")
+	if err := scheduler.populateTasks(); err != nil {
+		t.Error("Failed to populate tasks: ", err)
+	}
+
+	restoredTask, ok := scheduler.tasks[taskID]
+	if !ok {
+		t.Fatal("Task waiting to be retried should not be removed")
+	}
+	if restoredTask.Attempt != 1 || !restoredTask.NextRun.Equal(retryAt.Truncate(time.Second)) {
+		t.Error("Retry state should be restored from the store")
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	started := make(chan struct{}, 3)
+	stopped := make(chan error, 3)
+	waitForCancel := func(ctx context.Context, name string) {
+		started <- struct{}{}
+		<-ctx.Done()
+		stopped <- ctx.Err()
+	}
+
+	scheduler := New(storage.NewMemoryStorage())
+	_, _ = scheduler.RunAt(time.Now(), waitForCancel, "timeout", WithTimeout(50*time.Millisecond))
+	cancelled, _ := scheduler.RunAt(time.Now(), waitForCancel, "cancel")
+	_, _ = scheduler.RunAt(time.Now(), waitForCancel, "stop")
+	scheduler.runPending()
+	for i := 0; i < 3; i++ {
+		<-started
+	}
+
+	select {
+	case err := <-stopped:
+		if err != context.DeadlineExceeded {
+			t.Error("Context should be cancelled once the task's timeout expires")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Context was not cancelled after the timeout")
+	}
+
+	_ = scheduler.Cancel(cancelled)
+	select {
+	case err := <-stopped:
+		if err != context.Canceled {
+			t.Error("Context should be cancelled when the task is cancelled")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Context was not cancelled by Cancel")
+	}
+
+	scheduler.Start()
+	scheduler.Stop()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Context was not cancelled by Stop")
+	}
+}
+
+func TestShutdownDrainsExecutions(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	blocking := func(name string) {
+		started <- struct{}{}
+		<-release
+	}
+
+	memStore := storage.NewMemoryStorage()
+	scheduler := New(memStore)
+	taskID, _ := scheduler.RunEvery(time.Hour, blocking, "Test")
+	scheduler.tasks[taskID].NextRun = time.Now()
+	scheduler.queue.schedule(taskID, scheduler.tasks[taskID])
+	scheduler.Start()
+	<-started
+
+	shutdownErr := make(chan error)
+	go func() {
+		shutdownErr <- scheduler.Shutdown(context.Background())
+	}()
+	select {
+	case <-shutdownErr:
+		t.Fatal("Shutdown should wait for running executions")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-shutdownErr; err != nil {
+		t.Error("Shutdown should succeed once executions returned", err)
+	}
+	scheduler.Wait()
+
+	storedTasks, _ := memStore.Fetch()
+	if len(storedTasks) != 1 || storedTasks[0].LastRun == (time.Time{}).Format(time.RFC3339) {
+		t.Error("Shutdown should persist the last run of the task")
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	waitForCancel := func(ctx context.Context, name string) {
+		started <- struct{}{}
+		<-ctx.Done()
+		close(cancelled)
+	}
+
+	scheduler := New(storage.NewMemoryStorage())
+	taskID, _ := scheduler.RunAt(time.Now(), waitForCancel, "Test")
+	scheduler.Start()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := scheduler.Shutdown(ctx)
+	if err == nil || !strings.Contains(err.Error(), string(taskID)) {
+		t.Error("Shutdown should report the tasks which are still running", err)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("Running executions should be cancelled once the deadline is hit")
+	}
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	if !scheduler.taskStore.closed {
+		t.Error("Store should be closed after shutdown")
+	}
+}
+
+func TestStopWithoutStart(t *testing.T) {
+	scheduler := New(storage.NewMemoryStorage())
+	scheduler.Stop()
+	scheduler.Wait()
+
+	if err := scheduler.Start(); err == nil {
+		t.Error("A stopped scheduler should not start again")
+	}
+}
+
+func TestRun(t *testing.T) {
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	waitForCancel := func(ctx context.Context, name string) {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+	}
+
+	scheduler := New(storage.NewMemoryStorage())
+	_, _ = scheduler.RunAt(time.Now(), waitForCancel, "Test")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error)
+	go func() {
+		runErr <- scheduler.Run(ctx)
+	}()
+	<-started
+	if err := scheduler.Start(); err == nil {
+		t.Error("A running scheduler should not start again")
+	}
+
+	cancel()
+	select {
+	case err := <-runErr:
+		if err != nil {
+			t.Error("Run should return without an error once ctx is done", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run should return once ctx is done")
+	}
+	select {
+	case <-cancelled:
+	default:
+		t.Error("Running tasks should be cancelled before Run returns")
+	}
+}
+
+func TestRunShutdownTimeout(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	ignoreCancel := func(ctx context.Context, name string) {
+		close(started)
+		<-release
+	}
+
+	scheduler := New(storage.NewMemoryStorage(), WithShutdownTimeout(10*time.Millisecond))
+	_, _ = scheduler.RunAt(time.Now(), ignoreCancel, "Test")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error)
+	go func() {
+		runErr <- scheduler.Run(ctx)
+	}()
+	<-started
+
+	cancel()
+	select {
+	case err := <-runErr:
+		if err == nil || !strings.Contains(err.Error(), "running tasks") {
+			t.Error("Run should report the tasks which were still running, got", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run should return once the shutdown timeout elapsed")
+	}
+}
+
+func TestSignalHandling(t *testing.T) {
+	scheduler := New(storage.NewMemoryStorage(), WithSignalHandling(syscall.SIGHUP))
+	scheduler.Start()
+
+	process, _ := os.FindProcess(os.Getpid())
+	_ = process.Signal(syscall.SIGHUP)
+	stopped := make(chan struct{})
+	go func() {
+		scheduler.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Scheduler should stop once the signal is received")
+	}
+	scheduler.Stop()
+}
+
+// fakeClock is a Clock whose time only moves when it's set.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (clock *fakeClock) Now() time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	return clock.now
+}
+
+func (clock *fakeClock) NewTimer(d time.Duration) Timer {
+	return realClock{}.NewTimer(time.Hour)
+}
+
+func (clock *fakeClock) Set(now time.Time) {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	clock.now = now
+}
+
+func TestWithClock(t *testing.T) {
+	mock := task.CallbackMock{}
+	mock.On("CallNoArgs").Return()
+
+	clock := &fakeClock{now: time.Date(2018, time.October, 27, 9, 0, 0, 0, time.UTC)}
+	scheduler := New(storage.NewMemoryStorage(), WithClock(clock))
+	taskID, _ := scheduler.RunAfter(time.Hour, mock.CallNoArgs)
+	if !scheduler.tasks[taskID].NextRun.Equal(clock.Now().Add(time.Hour)) {
+		t.Error("Tasks should be scheduled using the scheduler's clock")
+	}
+
+	scheduler.runPending()
+	clock.Set(clock.Now().Add(time.Hour))
+	scheduler.runPending()
+	time.Sleep(100 * time.Millisecond)
+
+	mock.AssertNumberOfCalls(t, "CallNoArgs", 1)
+}
+
+func TestMisfireSkipToNext(t *testing.T) {
+	mock := task.CallbackMock{}
+	scheduler := New(storage.NewMemoryStorage(), WithMisfirePolicy(task.MisfireSkipToNext, time.Second))
+
+	oneOffID, _ := scheduler.RunAt(time.Now().Add(-time.Hour), mock.CallNoArgs)
+	recurringID, _ := scheduler.RunEvery(time.Minute, mock.CallWithArgs, "Test", true)
+	recurring := scheduler.tasks[recurringID]
+	recurring.NextRun = time.Now().Add(-10*time.Minute - time.Second)
+	scheduler.queue.schedule(recurringID, recurring)
+
+	scheduler.runPending()
+	time.Sleep(100 * time.Millisecond)
+
+	mock.AssertNotCalled(t, "CallNoArgs")
+	mock.AssertNotCalled(t, "CallWithArgs", "Test", true)
+	if _, ok := scheduler.tasks[oneOffID]; ok {
+		t.Error("Misfired one-off task should be removed")
+	}
+	if nextRun := recurring.NextRun; !nextRun.After(time.Now()) || nextRun.After(time.Now().Add(time.Minute)) {
+		t.Error("Misfired recurring task should wait for its next regular run, got", nextRun)
+	}
+}
+
+func TestMisfireFireOnceNow(t *testing.T) {
+	mock := task.CallbackMock{}
+	mock.On("CallNoArgs").Return()
+
+	scheduler := New(storage.NewMemoryStorage())
+	taskID, _ := scheduler.RunEvery(time.Minute, mock.CallNoArgs)
+	recurring := scheduler.tasks[taskID]
+	recurring.NextRun = time.Now().Add(-10 * time.Minute)
+	scheduler.queue.schedule(taskID, recurring)
+
+	scheduler.runPending()
+	time.Sleep(100 * time.Millisecond)
+
+	mock.AssertNumberOfCalls(t, "CallNoArgs", 1)
+	if !recurring.NextRun.After(time.Now()) {
+		t.Error("The runs missed in between should be skipped, got", recurring.NextRun)
+	}
+}
+
+func TestMisfireFireAllMissed(t *testing.T) {
+	var mu sync.Mutex
+	var scheduledTimes []time.Time
+	running := 0
+	catchUp := func(ctx context.Context, name string) {
+		mu.Lock()
+		running++
+		if running > 1 {
+			t.Error("Missed runs should be executed one after the other")
+		}
+		scheduledAt, _ := ScheduledTime(ctx)
+		scheduledTimes = append(scheduledTimes, scheduledAt)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+	}
+
+	scheduler := New(storage.NewMemoryStorage())
+	taskID, _ := scheduler.RunEvery(time.Minute, catchUp, "Test", WithCatchUp(0))
+	recurring := scheduler.tasks[taskID]
+	missedRun := time.Now().Add(-150 * time.Second)
+	recurring.NextRun = missedRun
+	scheduler.queue.schedule(taskID, recurring)
+	scheduler.Start()
+	time.Sleep(200 * time.Millisecond)
+	scheduler.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(scheduledTimes) != 3 {
+		t.Fatal("Every missed run should be executed, got", len(scheduledTimes))
+	}
+	for i, scheduledAt := range scheduledTimes {
+		if !scheduledAt.Equal(missedRun.Add(time.Duration(i) * time.Minute)) {
+			t.Error("Missed runs should be executed in order with their scheduled time, got", scheduledAt)
+		}
+	}
+	if !recurring.CurrentSchedule().NextRun.After(time.Now()) {
+		t.Error("Task should be rescheduled after its missed runs")
+	}
+}
+
+func TestCatchUpLimit(t *testing.T) {
+	mock := task.CallbackMock{}
+	mock.On("CallNoArgs").Return()
+	memStore := storage.NewMemoryStorage()
+
+	scheduler := New(memStore)
+	taskID, _ := scheduler.RunEvery(time.Minute, mock.CallNoArgs, WithCatchUp(2))
+	recurring := scheduler.tasks[taskID]
+	recurring.NextRun = time.Now().Add(-10 * time.Minute)
+	_ = scheduler.persistRegisteredTasks()
+
+	// The process restarts, the stored schedule is resumed
+	scheduler = New(memStore)
+	taskID, _ = scheduler.RunEvery(time.Minute, mock.CallNoArgs, WithCatchUp(2))
+	scheduler.Start()
+	time.Sleep(200 * time.Millisecond)
+	scheduler.Stop()
+
+	mock.AssertNumberOfCalls(t, "CallNoArgs", 2)
+	if nextRun := scheduler.tasks[taskID].CurrentSchedule().NextRun; !nextRun.After(time.Now()) {
+		t.Error("Remaining missed runs should be skipped, got", nextRun)
+	}
+}
+
+func TestMisfireDrop(t *testing.T) {
+	mock := task.CallbackMock{}
+	scheduler := New(storage.NewMemoryStorage())
+
+	onTimeID, _ := scheduler.RunEvery(time.Minute, mock.CallNoArgs, WithTaskMisfirePolicy(task.MisfireDrop, time.Hour))
+	lateID, _ := scheduler.RunEvery(time.Minute, mock.CallWithArgs, "Test", true, WithTaskMisfirePolicy(task.MisfireDrop, 0))
+	for _, taskID := range []task.ID{onTimeID, lateID} {
+		scheduler.tasks[taskID].NextRun = time.Now().Add(-10 * time.Minute)
+		scheduler.queue.schedule(taskID, scheduler.tasks[taskID])
+	}
+	mock.On("CallNoArgs").Return()
+
+	scheduler.runPending()
+	time.Sleep(100 * time.Millisecond)
+
+	mock.AssertNumberOfCalls(t, "CallNoArgs", 1)
+	mock.AssertNotCalled(t, "CallWithArgs", "Test", true)
+	if _, ok := scheduler.tasks[lateID]; ok {
+		t.Error("Misfired task should be dropped")
+	}
+	if _, ok := scheduler.tasks[onTimeID]; !ok {
+		t.Error("Task within its misfire grace should be kept")
+	}
+}
+
+func TestScheduleIsPersisted(t *testing.T) {
+	mock := task.CallbackMock{}
+	mock.On("CallNoArgs").Return()
+	memStore := storage.NewMemoryStorage()
+
+	scheduler := New(memStore)
+	taskID, _ := scheduler.RunEvery(time.Hour, mock.CallNoArgs)
+	scheduler.tasks[taskID].NextRun = time.Now()
+	scheduler.queue.schedule(taskID, scheduler.tasks[taskID])
+	_ = scheduler.persistRegisteredTasks()
+	scheduler.runPending()
+	nextRun := scheduler.tasks[taskID].CurrentSchedule().NextRun
+
+	// The process restarts, the task resumes from the stored schedule
+	scheduler = New(memStore)
+	taskID, _ = scheduler.RunEvery(time.Hour, mock.CallNoArgs)
+	scheduler.mu.Lock()
+	_ = scheduler.populateTasks()
+	scheduler.unlock()
+
+	if !scheduler.tasks[taskID].NextRun.Equal(nextRun.Truncate(time.Second)) {
+		t.Errorf("The schedule should be resumed from the store, got %s instead of %s",
+			scheduler.tasks[taskID].NextRun, nextRun)
+	}
+}
+
+// slowStore blocks the updates of the tasks until it's released.
+type slowStore struct {
+	*storage.MemoryStorage
+	updating chan struct{}
+	release  chan struct{}
+}
+
+func (store *slowStore) Update(task storage.TaskAttributes) error {
+	select {
+	case store.updating <- struct{}{}:
+	default:
+	}
+	<-store.release
+	return store.MemoryStorage.Update(task)
+}
+
+func TestScheduleIsPersistedOutsideLock(t *testing.T) {
+	mock := task.CallbackMock{}
+	mock.On("CallNoArgs").Return()
+	store := &slowStore{
+		MemoryStorage: storage.NewMemoryStorage(),
+		updating:      make(chan struct{}, 1),
+		release:       make(chan struct{}),
+	}
+
+	scheduler := New(store)
+	taskID, _ := scheduler.RunEvery(time.Hour, mock.CallNoArgs)
+	scheduler.tasks[taskID].NextRun = time.Now()
+	scheduler.queue.schedule(taskID, scheduler.tasks[taskID])
+	dispatched := make(chan struct{})
+	go func() {
+		scheduler.runPending()
+		close(dispatched)
+	}()
+	<-store.updating
+
+	// The scheduler can be used while the dispatched task's schedule is being stored
+	listed := make(chan struct{})
+	go func() {
+		scheduler.Tasks()
+		close(listed)
+	}()
+	select {
+	case <-listed:
+		close(store.release)
+	case <-time.After(time.Second):
+		close(store.release)
+		t.Fatal("The scheduler should not be locked while the store is updated")
+	}
+
+	<-dispatched
+	stored, _ := store.Fetch()
+	if len(stored) != 1 || stored[0].LastRun == stored[0].NextRun {
+		t.Errorf("The schedule of the dispatched task should be stored, got %+v", stored)
+	}
+}
+
+func TestPopulateTasksAppliesMisfirePolicy(t *testing.T) {
+	mock := task.CallbackMock{}
+	memStore := storage.NewMemoryStorage()
+
+	scheduler := New(memStore)
+	_, _ = scheduler.RunAt(time.Now().Add(-time.Hour), mock.CallNoArgs)
+	_ = scheduler.persistRegisteredTasks()
+
+	// The process restarts after the one-off task was due, only the function is known
+	mock.On("CallNoArgs").Return()
+	scheduler = New(memStore)
+	_, _ = scheduler.funcRegistry.Add(mock.CallNoArgs)
+	scheduler.Start()
+	defer scheduler.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	mock.AssertNumberOfCalls(t, "CallNoArgs", 1)
+}
+
+func TestPopulateTasksAppliesStoredMisfirePolicy(t *testing.T) {
+	mock := task.CallbackMock{}
+	memStore := storage.NewMemoryStorage()
+
+	scheduler := New(memStore)
+	_, _ = scheduler.RunAt(time.Now().Add(-time.Hour), mock.CallNoArgs, WithTaskMisfirePolicy(task.MisfireDrop, 0))
+	_ = scheduler.persistRegisteredTasks()
+
+	// The process restarts after the one-off task was due, the stored task keeps its own policy
+	scheduler = New(memStore)
+	_, _ = scheduler.funcRegistry.Add(mock.CallNoArgs)
+	scheduler.Start()
+	defer scheduler.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	mock.AssertNotCalled(t, "CallNoArgs")
+	if storedTasks, _ := memStore.Fetch(); len(storedTasks) != 0 {
+		t.Errorf("The dropped task should be removed from the store, got %+v", storedTasks)
+	}
+}
+
+// concurrencyTracker records how many calls of its function run at the same time.
+type concurrencyTracker struct {
+	mu         sync.Mutex
+	running    int
+	maxRunning int
+	calls      int
+}
+
+func (tracker *concurrencyTracker) track(name string) {
+	tracker.mu.Lock()
+	tracker.running++
+	tracker.calls++
+	if tracker.running > tracker.maxRunning {
+		tracker.maxRunning = tracker.running
+	}
+	tracker.mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	tracker.mu.Lock()
+	tracker.running--
+	tracker.mu.Unlock()
+}
+
+// trackOther is registered as a different function than track.
+func (tracker *concurrencyTracker) trackOther(name string) {
+	tracker.track(name)
+}
+
+func (tracker *concurrencyTracker) stats() (calls int, maxRunning int) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	return tracker.calls, tracker.maxRunning
+}
+
+func TestWorkerPoolSize(t *testing.T) {
+	tracker := &concurrencyTracker{}
+	results := make(chan task.Result, 5)
+	scheduler := New(storage.NewMemoryStorage(), WithWorkerPoolSize(2))
+	scheduler.OnResult(func(result task.Result) {
+		results <- result
+	})
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		_, _ = scheduler.RunAt(time.Now(), tracker.track, name)
+	}
+	scheduler.Start()
+	defer scheduler.Stop()
+
+	for i := 0; i < 5; i++ {
+		select {
+		case <-results:
+		case <-time.After(time.Second):
+			t.Fatal("Blocked runs should be dispatched once a worker is available")
+		}
+	}
+	if _, maxRunning := tracker.stats(); maxRunning != 2 {
+		t.Error("Executions should be limited by the worker pool size, got", maxRunning)
+	}
+}
+
+func TestWorkerPoolQueueFullDrop(t *testing.T) {
+	tracker := &concurrencyTracker{}
+	results := make(chan task.Result, 3)
+	scheduler := New(storage.NewMemoryStorage(), WithWorkerPool(WorkerPool{
+		Size:      1,
+		QueueSize: 1,
+		QueueFull: QueueFullDrop,
+	}))
+	scheduler.OnResult(func(result task.Result) {
+		results <- result
+	})
+	for _, name := range []string{"a", "b", "c"} {
+		_, _ = scheduler.RunAt(time.Now(), tracker.track, name)
+	}
+	scheduler.runPending()
+
+	dropped := 0
+	for i := 0; i < 3; i++ {
+		if result := <-results; result.Err == ErrQueueFull {
+			dropped++
+		}
+	}
+	if calls, _ := tracker.stats(); calls != 2 || dropped != 1 {
+		t.Errorf("Runs over the queue size should be dropped, got %d calls and %d dropped", calls, dropped)
+	}
+}
+
+func TestWorkerPoolQueueFullDefer(t *testing.T) {
+	release := make(chan struct{})
+	blocking := func(name string) {
+		<-release
+	}
+	defer close(release)
+
+	scheduler := New(storage.NewMemoryStorage(), WithWorkerPool(WorkerPool{
+		Size:       1,
+		QueueFull:  QueueFullDefer,
+		DeferDelay: time.Minute,
+	}))
+	_, _ = scheduler.RunAt(time.Now(), blocking, "a")
+	deferredID, _ := scheduler.RunAt(time.Now(), blocking, "b")
+	scheduler.runPending()
+
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	item := scheduler.queue.peek()
+	if item == nil || item.id != deferredID || item.nextRun.Before(time.Now().Add(50*time.Second)) {
+		t.Error("Run over the queue size should be deferred by the defer delay")
+	}
+	if !scheduler.tasks[deferredID].NextRun.Before(time.Now()) {
+		t.Error("Deferring a run should not change the task's schedule")
+	}
+}
+
+func TestFunctionConcurrency(t *testing.T) {
+	limited := &concurrencyTracker{}
+	other := &concurrencyTracker{}
+	results := make(chan task.Result, 6)
+	scheduler := New(storage.NewMemoryStorage(), WithFunctionConcurrency(task.FuncName(limited.track), 1))
+	scheduler.OnResult(func(result task.Result) {
+		results <- result
+	})
+	for _, name := range []string{"a", "b", "c"} {
+		_, _ = scheduler.RunAt(time.Now(), limited.track, name)
+		_, _ = scheduler.RunAt(time.Now(), other.trackOther, name)
+	}
+	scheduler.Start()
+	defer scheduler.Stop()
+
+	for i := 0; i < 6; i++ {
+		select {
+		case <-results:
+		case <-time.After(time.Second):
+			t.Fatal("Runs held back by the function limit should run eventually")
+		}
+	}
+	if calls, maxRunning := limited.stats(); calls != 3 || maxRunning != 1 {
+		t.Errorf("Function should run once at a time, got %d concurrent calls", maxRunning)
+	}
+	if _, maxRunning := other.stats(); maxRunning != 3 {
+		t.Error("Other functions should not be held back, got", maxRunning)
+	}
+}
+
+// overlapTest dispatches runs of a long running recurring task with the given overlap policy.
+type overlapTest struct {
+	scheduler *Scheduler
+	taskID    task.ID
+	started   chan string
+	cancelled chan string
+	release   chan struct{}
+	results   chan task.Result
+}
+
+func newOverlapTest(policy task.OverlapPolicy) *overlapTest {
+	test := &overlapTest{
+		started:   make(chan string, 3),
+		cancelled: make(chan string, 3),
+		release:   make(chan struct{}),
+		results:   make(chan task.Result, 3),
+	}
+	longRunning := func(ctx context.Context, name string) {
+		test.started <- name
+		select {
+		case <-test.release:
+		case <-ctx.Done():
+			test.cancelled <- name
+		}
+	}
+	test.scheduler = New(storage.NewMemoryStorage())
+	test.scheduler.OnResult(func(result task.Result) {
+		test.results <- result
+	})
+	test.taskID, _ = test.scheduler.RunEvery(time.Hour, longRunning, "Test", WithOverlapPolicy(policy))
+	return test
+}
+
+// dispatch makes the task due and dispatches it, it returns the time at which the run was due.
+func (test *overlapTest) dispatch() time.Time {
+	dueAt := time.Now()
+	test.scheduler.mu.Lock()
+	test.scheduler.queue.scheduleAt(test.taskID, test.scheduler.tasks[test.taskID], dueAt)
+	test.scheduler.mu.Unlock()
+	test.scheduler.runPending()
+	return dueAt
+}
+
+func (test *overlapTest) expect(t *testing.T, events chan string, description string) {
+	select {
+	case <-events:
+	case <-time.After(time.Second):
+		t.Fatal(description)
+	}
+}
+
+func (test *overlapTest) expectSkipped(t *testing.T) {
+	select {
+	case result := <-test.results:
+		if result.Err != ErrOverlap {
+			t.Error("Overlapping run should be reported as skipped, got", result.Err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Overlapping run should be reported")
+	}
+}
+
+func TestOverlapSkip(t *testing.T) {
+	test := newOverlapTest(task.OverlapSkip)
+	test.dispatch()
+	test.expect(t, test.started, "Task should start")
+	test.dispatch()
+	test.expectSkipped(t)
+
+	close(test.release)
+	<-test.results
+	if len(test.started) != 0 {
+		t.Error("Overlapping run should not be executed")
+	}
+}
+
+func TestOverlapQueueOne(t *testing.T) {
+	test := newOverlapTest(task.OverlapQueueOne)
+	test.dispatch()
+	test.expect(t, test.started, "Task should start")
+	queuedAt := test.dispatch()
+	test.dispatch()
+	test.expectSkipped(t)
+	if len(test.started) != 0 {
+		t.Error("Queued run should wait for the running one")
+	}
+
+	close(test.release)
+	test.expect(t, test.started, "Queued run should start once the running one finished")
+	first, second := <-test.results, <-test.results
+	if !first.ScheduledAt.Equal(queuedAt) && !second.ScheduledAt.Equal(queuedAt) {
+		t.Errorf("Queued run should keep the time it was due at %s, got %s and %s",
+			queuedAt, first.ScheduledAt, second.ScheduledAt)
+	}
+	if len(test.started) != 0 {
+		t.Error("Only one overlapping run should be queued")
+	}
+}
+
+func TestOverlapCancelPrevious(t *testing.T) {
+	test := newOverlapTest(task.OverlapCancelPrevious)
+	test.dispatch()
+	test.expect(t, test.started, "Task should start")
+	test.dispatch()
+	test.expect(t, test.cancelled, "Previous run should be cancelled")
+	test.expect(t, test.started, "New run should start")
+	close(test.release)
+}
+
+func TestWithLoggerAndErrorHandler(t *testing.T) {
+	mock := task.CallbackMock{}
+	mock.On("CallWithPanic").Return()
+
+	var output bytes.Buffer
+	errs := make(chan error, 1)
+	scheduler := New(newStoreMockWithMode(failOnRemove),
+		WithLogger(slog.New(slog.NewTextHandler(&output, nil))),
+		WithErrorHandler(func(err error) {
+			errs <- err
+		}),
+	)
+	results := make(chan task.Result, 1)
+	scheduler.OnResult(func(result task.Result) {
+		results <- result
+	})
+	_, _ = scheduler.RunAt(time.Now(), mock.CallWithPanic)
+	scheduler.runPending()
+
+	select {
+	case <-errs:
+	case <-time.After(time.Second):
+		t.Fatal("Failing to remove the executed task should be reported")
+	}
+	<-results
+	if !strings.Contains(output.String(), "Task panicked") || !strings.Contains(output.String(), "func=") {
+		t.Error("Panics should be logged using the scheduler's logger")
+	}
+}
+
+func TestErrorHandlerUsesScheduler(t *testing.T) {
+	mock := task.CallbackMock{}
+	mock.On("CallNoArgs").Return()
+
+	var scheduler *Scheduler
+	tasks := make(chan int, 1)
+	scheduler = New(newStoreMockWithMode(failOnRemove), WithErrorHandler(func(err error) {
+		tasks <- len(scheduler.Tasks())
+	}))
+	taskID, _ := scheduler.RunAt(time.Now().Add(time.Hour), mock.CallNoArgs)
+	_ = scheduler.Cancel(taskID)
+
+	select {
+	case count := <-tasks:
+		if count != 0 {
+			t.Errorf("The cancelled task should be removed, got %d tasks", count)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The error handler should be able to use the scheduler")
+	}
+}
+
+func TestHistory(t *testing.T) {
+	mock := task.CallbackMock{}
+	mock.On("CallWithError", "Test").Return(errors.New("Failed"))
+	mock.On("CallNoArgs").Return()
+
+	store := storage.NewMemoryStorage()
+	results := make(chan task.Result, 2)
+	scheduler := New(store, WithHistory(store, 0), WithNodeID("node-1"))
+	scheduler.OnResult(func(result task.Result) {
+		results <- result
+	})
+	failingID, _ := scheduler.RunAt(time.Now(), mock.CallWithError, "Test")
+	_, _ = scheduler.RunAt(time.Now(), mock.CallNoArgs)
+	scheduler.runPending()
+	<-results
+	<-results
+
+	entries, err := scheduler.History("", HistoryFilter{})
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("Both executions should be recorded, got %d entries and error %v", len(entries), err)
+	}
+
+	entries, _ = scheduler.History(failingID, HistoryFilter{})
+	if len(entries) != 1 {
+		t.Fatalf("The history should be filtered by task, got %d entries", len(entries))
+	}
+	entry := entries[0]
+	if entry.Outcome != OutcomeFailed || entry.Error != "Failed" || entry.Attempt != 1 || entry.NodeID != "node-1" {
+		t.Errorf("The failed execution should be recorded, got %+v", entry)
+	}
+	if entry.FinishedAt.Before(entry.StartedAt) || entry.Duration < 0 {
+		t.Errorf("The execution's times should be recorded, got %+v", entry)
+	}
+
+	entries, _ = scheduler.History("", HistoryFilter{Outcome: OutcomeSucceeded})
+	if len(entries) != 1 || entries[0].TaskID == failingID {
+		t.Error("The history should be filtered by outcome")
+	}
+	entries, _ = scheduler.History("", HistoryFilter{Since: time.Now()})
+	if len(entries) != 0 {
+		t.Error("The history should be filtered by start time")
+	}
+
+	if _, err := New(store).History(failingID, HistoryFilter{}); err != ErrHistoryDisabled {
+		t.Error("History should fail when the scheduler has no history store")
+	}
+}
+
+func TestHistoryRetention(t *testing.T) {
+	mock := task.CallbackMock{}
+	mock.On("CallNoArgs").Return()
+
+	clock := &fakeClock{now: time.Date(2018, time.October, 27, 9, 0, 0, 0, time.UTC)}
+	store := storage.NewMemoryStorage()
+	old := clock.Now().Add(-2 * time.Hour)
+	_ = store.AddRecord(storage.HistoryRecord{
+		TaskHash:    "old",
+		ScheduledAt: formatHistoryTime(old),
+		StartedAt:   formatHistoryTime(old),
+		FinishedAt:  formatHistoryTime(old),
+		Duration:    "0s",
+		Outcome:     string(OutcomeSucceeded),
+		Attempt:     "1",
+	})
+
+	results := make(chan task.Result, 1)
+	scheduler := New(store, WithClock(clock), WithHistory(store, time.Hour))
+	scheduler.OnResult(func(result task.Result) {
+		results <- result
+	})
+	taskID, _ := scheduler.RunAt(clock.Now(), mock.CallNoArgs)
+	scheduler.runPending()
+	<-results
+
+	entries, _ := scheduler.History("", HistoryFilter{})
+	if len(entries) != 1 || entries[0].TaskID != taskID {
+		t.Errorf("Entries older than the retention should be pruned, got %+v", entries)
+	}
+}
+
+func TestStartDispatchesOnTime(t *testing.T) {
+	executed := make(chan time.Time, 1)
+	scheduler := New(storage.NewMemoryStorage())
+	scheduler.Start()
+	defer scheduler.Stop()
+
+	// Scheduling after the dispatcher started should re-arm its timer
+	dueTime := time.Now().Add(100 * time.Millisecond)
+	_, err := scheduler.RunAt(dueTime, func() {
+		executed <- time.Now()
+	})
+	if err != nil {
+		t.Error("Creating a task should succeed")
+	}
+
+	select {
+	case executedAt := <-executed:
+		if executedAt.Before(dueTime) || executedAt.Sub(dueTime) > 50*time.Millisecond {
+			t.Error("Task should be dispatched when it is due, delay:", executedAt.Sub(dueTime))
+		}
+	case <-time.After(time.Second):
+		t.Error("Task was not dispatched")
+	}
+}
+
+func TestConcurrentScheduling(t *testing.T) {
+	var executions sync.WaitGroup
+	scheduler := New(storage.NewMemoryStorage())
+	// Persisting every dispatch makes shorter intervals saturate the dispatcher with the race detector
+	_, _ = scheduler.RunEvery(10*time.Millisecond, func() {})
+	scheduler.Start()
+	defer scheduler.Stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				executions.Add(1)
+				_, err := scheduler.RunAfter(time.Millisecond, func(i, j int) {
+					executions.Done()
+				}, i, j)
+				if err != nil {
+					t.Error("Creating a task should succeed")
+				}
+
+				taskID, _ := scheduler.RunEvery(10*time.Millisecond, func(i, j int) {}, i, j)
+				time.Sleep(time.Millisecond)
+				if err := scheduler.Cancel(taskID); err != nil {
+					t.Error("Cancelling a scheduled task should succeed")
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		executions.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("Not all tasks were executed")
+	}
+}
+
+func TestWithID(t *testing.T) {
+	mock := task.CallbackMock{}
+	scheduler := New(storage.NewMemoryStorage())
+
+	firstID, _ := scheduler.RunAt(time.Now().Add(time.Hour), mock.CallNoArgs, WithID("reminder-1"))
+	secondID, _ := scheduler.RunAt(time.Now().Add(time.Hour), mock.CallNoArgs, WithID("reminder-2"))
+	if firstID != "reminder-1" || secondID != "reminder-2" || len(scheduler.tasks) != 2 {
+		t.Error("Tasks should be scheduled with the provided IDs")
+	}
+}
+
+func TestRunAtDistinctTimes(t *testing.T) {
+	mock := task.CallbackMock{}
+	scheduler := New(storage.NewMemoryStorage())
+	firstID, _ := scheduler.RunAt(time.Now().Add(time.Hour), mock.CallWithArgs, "Hello", true)
+	secondID, _ := scheduler.RunAt(time.Now().Add(2*time.Hour), mock.CallWithArgs, "Hello", true)
+	if firstID == secondID || len(scheduler.tasks) != 2 {
+		t.Error("The same call scheduled at different times should make distinct tasks")
+	}
+}
+
+func TestConflictMode(t *testing.T) {
+	mock := task.CallbackMock{}
+	first := time.Now().Add(time.Hour)
+	second := first.Add(time.Hour)
+
+	scheduler := New(storage.NewMemoryStorage())
+	_, _ = scheduler.RunAt(first, mock.CallNoArgs, WithID("reminder"))
+	_, err := scheduler.RunAt(second, mock.CallNoArgs, WithID("reminder"))
+	if err != nil || !scheduler.tasks["reminder"].NextRun.Equal(second) {
+		t.Error("The scheduled task should be replaced by default")
+	}
+
+	scheduler = New(storage.NewMemoryStorage(), WithConflictMode(ConflictReject))
+	_, _ = scheduler.RunAt(first, mock.CallNoArgs, WithID("reminder"))
+	if _, err := scheduler.RunAt(second, mock.CallNoArgs, WithID("reminder")); err != ErrTaskExists {
+		t.Error("Scheduling a task with an existing ID should be rejected")
+	}
+	if !scheduler.tasks["reminder"].NextRun.Equal(first) {
+		t.Error("The scheduled task should be kept when the new one is rejected")
+	}
+
+	scheduler = New(storage.NewMemoryStorage(), WithConflictMode(ConflictIgnore))
+	_, _ = scheduler.RunAt(first, mock.CallNoArgs, WithID("reminder"))
+	taskID, err := scheduler.RunAt(second, mock.CallNoArgs, WithID("reminder"))
+	if err != nil || taskID != "reminder" || !scheduler.tasks["reminder"].NextRun.Equal(first) {
+		t.Error("The scheduled task should be kept when the new one is ignored")
+	}
+}
+
+func TestConflictModeWithStoredTask(t *testing.T) {
+	mock := task.CallbackMock{}
+	storeTask := func() *storage.MemoryStorage {
+		store := storage.NewMemoryStorage()
+		scheduler := New(store)
+		_, _ = scheduler.RunEvery(time.Hour, mock.CallWithArgs, "Stored", true, WithID("job"))
+		_ = scheduler.persistRegisteredTasks()
+		return store
+	}
+	// The process restarts and the task is registered again with other params
+	restart := func(store *storage.MemoryStorage, mode ConflictMode) (*Scheduler, []error) {
+		var errs []error
+		scheduler := New(store, WithConflictMode(mode), WithErrorHandler(func(err error) {
+			errs = append(errs, err)
+		}))
+		_, _ = scheduler.RunEvery(time.Hour, mock.CallWithArgs, "Registered", true, WithID("job"))
+		if err := scheduler.Start(); err != nil {
+			t.Fatal("Could not start the scheduler: ", err)
+		}
+		scheduler.Stop()
+		return scheduler, errs
+	}
+
+	for _, mode := range []ConflictMode{ConflictReplace, ConflictReject, ConflictIgnore} {
+		store := storeTask()
+		scheduler, errs := restart(store, mode)
+
+		want := "Stored"
+		if mode == ConflictReplace {
+			want = "Registered"
+		}
+		if params := scheduler.tasks["job"].Params; params[0] != want {
+			t.Errorf("Conflict mode %d should keep the task called with %s, got %v", mode, want, params)
+		}
+		stored, _ := store.Fetch()
+		if len(stored) != 1 || !strings.Contains(stored[0].Params, want) {
+			t.Errorf("Conflict mode %d should store the task called with %s once, got %+v", mode, want, stored)
+		}
+		if rejected := len(errs) == 1 && errors.Is(errs[0], ErrTaskExists); rejected != (mode == ConflictReject) {
+			t.Errorf("Conflict mode %d reported unexpected errors: %v", mode, errs)
+		}
+	}
+
+	// Registering the stored task again isn't a conflict
+	store := storeTask()
+	scheduler := New(store, WithConflictMode(ConflictReject))
+	_, _ = scheduler.RunEvery(time.Hour, mock.CallWithArgs, "Stored", true, WithID("job"))
+	if err := scheduler.Start(); err != nil {
+		t.Fatal("Could not start the scheduler: ", err)
+	}
+	scheduler.Stop()
+	if stored, _ := store.Fetch(); len(stored) != 1 {
+		t.Errorf("The task should be stored once, got %+v", stored)
+	}
+}
+
+func TestReplaceStartedTask(t *testing.T) {
+	mock := task.CallbackMock{}
+	store := storage.NewMemoryStorage()
+	scheduler := New(store)
+	_, _ = scheduler.RunEvery(time.Hour, mock.CallNoArgs, WithID("report"))
+	_ = scheduler.Start()
+	defer scheduler.Stop()
+
+	_, _ = scheduler.RunEvery(2*time.Hour, mock.CallNoArgs, WithID("report"))
+	stored, _ := store.Fetch()
+	if len(stored) != 1 || stored[0].ID != "report" || stored[0].Duration != "2h0m0s" {
+		t.Errorf("The replaced task should be replaced in the store, got %+v", stored)
+	}
+}
+
+func TestReschedule(t *testing.T) {
+	mock := task.CallbackMock{}
+	scheduler := New(storage.NewMemoryStorage())
+	taskID, _ := scheduler.RunAt(time.Now().Add(time.Hour), mock.CallNoArgs)
+
+	at := time.Now().Add(2 * time.Hour)
+	if err := scheduler.Reschedule(taskID, at); err != nil {
+		t.Fatal("Rescheduling a task should succeed")
+	}
+	if !scheduler.tasks[taskID].NextRun.Equal(at) || !scheduler.queue.peek().nextRun.Equal(at) {
+		t.Error("The task should be due at the new time")
+	}
+	if scheduler.tasks[taskID].Hash() != taskID {
+		t.Error("The rescheduled task should keep its ID")
+	}
+	if err := scheduler.Reschedule("unknown", at); err == nil {
+		t.Error("Rescheduling an unknown task should fail")
+	}
+}
+
+func TestUpdateInterval(t *testing.T) {
+	mock := task.CallbackMock{}
+	memStore := storage.NewMemoryStorage()
+	scheduler := New(memStore)
+	taskID, _ := scheduler.RunEvery(time.Hour, mock.CallNoArgs)
+	_ = scheduler.Start()
+	defer scheduler.Stop()
+
+	if err := scheduler.UpdateInterval(taskID, time.Minute); err != nil {
+		t.Fatal("Updating the interval should succeed")
+	}
+	schedule := scheduler.tasks[taskID].CurrentSchedule()
+	if schedule.Duration != time.Minute || schedule.NextRun.After(time.Now().Add(time.Minute)) {
+		t.Error("The next run should follow the new interval")
+	}
+	stored, _ := memStore.Fetch()
+	if len(stored) != 1 || stored[0].Hash != string(taskID) || stored[0].Duration != "1m0s" {
+		t.Errorf("The new interval should be stored under the task's ID, got %+v", stored)
+	}
+
+	oneOffID, _ := scheduler.RunAt(time.Now().Add(time.Hour), mock.CallNoArgs)
+	if err := scheduler.UpdateInterval(oneOffID, time.Minute); err == nil {
+		t.Error("Updating the interval of a one-off task should fail")
+	}
+}
+
+func TestUpdateParams(t *testing.T) {
+	mock := task.CallbackMock{}
+	mock.On("CallWithArgs", "World", false).Return()
+	scheduler := New(storage.NewMemoryStorage())
+	taskID, _ := scheduler.RunAt(time.Now(), mock.CallWithArgs, "Hello", true)
+
+	if err := scheduler.UpdateParams(taskID, "World"); err == nil {
+		t.Error("Params which don't match the function should be rejected")
+	}
+	if err := scheduler.UpdateParams(taskID, "World", false); err != nil {
+		t.Fatal("Updating the params should succeed")
+	}
+	if _, ok := scheduler.tasks[taskID]; !ok {
+		t.Fatal("The task should keep its ID")
+	}
+
+	scheduler.runPending()
+	time.Sleep(100 * time.Millisecond)
+	mock.AssertExpectations(t)
+}
+
+func TestModifiedTaskSurvivesRestart(t *testing.T) {
+	mock := task.CallbackMock{}
+	memStore := storage.NewMemoryStorage()
+	scheduler := New(memStore)
+	taskID, _ := scheduler.RunEvery(time.Hour, mock.CallWithArgs, "Hello", true)
+	_ = scheduler.Start()
+	if err := scheduler.UpdateInterval(taskID, time.Minute); err != nil {
+		t.Fatal("Updating the interval should succeed")
+	}
+	if err := scheduler.UpdateParams(taskID, "World", false); err != nil {
+		t.Fatal("Updating the params should succeed")
+	}
+	scheduler.Stop()
+
+	// The process restarts and the task is registered again as it's written in the code
+	scheduler = New(memStore)
+	taskID, _ = scheduler.RunEvery(time.Hour, mock.CallWithArgs, "Hello", true)
+	scheduler.mu.Lock()
+	_ = scheduler.populateTasks()
+	scheduler.unlock()
+
+	info, _ := scheduler.Task(taskID)
+	if info.Duration != time.Minute || len(info.Params) != 2 || info.Params[0] != "World" {
+		t.Errorf("The changes should survive a restart, got %s and %v", info.Duration, info.Params)
+	}
+	if !info.NextRun.Before(time.Now().Add(2 * time.Minute)) {
+		t.Error("The task should keep the stored cadence, got", info.NextRun)
+	}
+}
+
+func TestTasksSnapshot(t *testing.T) {
+	mock := task.CallbackMock{}
+	mock.On("CallWithArgs", "Hello", true).Return()
+	scheduler := New(storage.NewMemoryStorage())
+	laterID, _ := scheduler.RunEvery(time.Hour, mock.CallNoArgs)
+	soonID, _ := scheduler.RunAt(time.Now().Add(time.Minute), mock.CallWithArgs, "Hello", true)
+
+	infos := scheduler.Tasks()
+	if len(infos) != 2 || infos[0].ID != soonID || infos[1].ID != laterID {
+		t.Fatal("Tasks should be listed by their next run")
+	}
+	if infos[0].FuncName != scheduler.tasks[soonID].Func.Name || infos[0].Params[1] != true ||
+		infos[0].Status != StatusScheduled || infos[1].Duration != time.Hour {
+		t.Errorf("The snapshot should describe the task, got %+v", infos[0])
+	}
+
+	infos[0].Params[0] = "Changed"
+	infos[0].NextRun = time.Time{}
+	if info, ok := scheduler.Task(soonID); !ok || info.Params[0] != "Hello" || info.NextRun.IsZero() {
+		t.Error("Changing a snapshot should not affect the task")
+	}
+	if _, ok := scheduler.Task("unknown"); ok {
+		t.Error("Unknown tasks should not be found")
+	}
+
+	upcoming := scheduler.Upcoming(10 * time.Minute)
+	if len(upcoming) != 1 || upcoming[0].ID != soonID {
+		t.Error("Only the tasks due within the window should be upcoming")
+	}
+}
+
+func TestTaskSnapshotRunCount(t *testing.T) {
+	release := make(chan struct{})
+	scheduler := New(storage.NewMemoryStorage())
+	taskID, _ := scheduler.RunEvery(time.Hour, func() {
+		<-release
+	})
+	scheduler.tasks[taskID].NextRun = time.Now()
+	scheduler.queue.schedule(taskID, scheduler.tasks[taskID])
+	scheduler.runPending()
+
+	if info, _ := scheduler.Task(taskID); info.Status != StatusRunning || info.RunCount != 1 {
+		t.Errorf("The task should be running for the first time, got %+v", info)
+	}
+	close(release)
+}
+
+func TestPauseResume(t *testing.T) {
+	mock := task.CallbackMock{}
+	mock.On("CallNoArgs").Return()
+	scheduler := New(storage.NewMemoryStorage())
+	taskID, _ := scheduler.RunEvery(time.Hour, mock.CallNoArgs)
+	scheduler.tasks[taskID].NextRun = time.Now()
+	scheduler.queue.schedule(taskID, scheduler.tasks[taskID])
+
+	if err := scheduler.Pause(taskID); err != nil {
+		t.Fatal("Pausing a task should succeed")
+	}
+	scheduler.runPending()
+	time.Sleep(50 * time.Millisecond)
+	mock.AssertNumberOfCalls(t, "CallNoArgs", 0)
+	if info, _ := scheduler.Task(taskID); info.Status != StatusPaused {
+		t.Error("The task should be reported as paused")
+	}
+
+	if err := scheduler.Resume(taskID); err != nil {
+		t.Fatal("Resuming a task should succeed")
+	}
+	scheduler.runPending()
+	time.Sleep(50 * time.Millisecond)
+	mock.AssertNumberOfCalls(t, "CallNoArgs", 1)
+
+	if err := scheduler.Pause("unknown"); err == nil {
+		t.Error("Pausing an unknown task should fail")
+	}
+}
+
+func TestPauseAll(t *testing.T) {
+	mock := task.CallbackMock{}
+	mock.On("CallNoArgs").Return()
+	scheduler := New(storage.NewMemoryStorage())
+	_, _ = scheduler.RunAt(time.Now(), mock.CallNoArgs)
+
+	scheduler.PauseAll()
+	scheduler.runPending()
+	time.Sleep(50 * time.Millisecond)
+	mock.AssertNumberOfCalls(t, "CallNoArgs", 0)
+
+	scheduler.ResumeAll()
+	scheduler.runPending()
+	time.Sleep(50 * time.Millisecond)
+	mock.AssertNumberOfCalls(t, "CallNoArgs", 1)
+}
+
+func TestPausedTaskIsPersisted(t *testing.T) {
+	mock := task.CallbackMock{}
+	memStore := storage.NewMemoryStorage()
+	scheduler := New(memStore)
+	taskID, _ := scheduler.RunEvery(time.Hour, mock.CallNoArgs)
+	_ = scheduler.Start()
+	_ = scheduler.Pause(taskID)
+	scheduler.Stop()
+
+	// The process restarts, the task is registered again but stays paused
+	scheduler = New(memStore)
+	taskID, _ = scheduler.RunEvery(time.Hour, mock.CallNoArgs)
+	scheduler.mu.Lock()
+	_ = scheduler.populateTasks()
+	scheduler.mu.Unlock()
+
+	if !scheduler.tasks[taskID].Paused || scheduler.queue.byID[taskID] != nil {
+		t.Error("The task should stay paused after a restart")
+	}
+}
+
+func TestTriggerNow(t *testing.T) {
+	mock := task.CallbackMock{}
+	mock.On("CallNoArgs").Return()
+	store := storage.NewMemoryStorage()
+	results := make(chan task.Result, 1)
+	scheduler := New(store, WithHistory(store, 0))
+	scheduler.OnResult(func(result task.Result) {
+		results <- result
+	})
+	taskID, _ := scheduler.RunEvery(time.Hour, mock.CallNoArgs)
+	nextRun := scheduler.tasks[taskID].NextRun
+
+	if err := scheduler.TriggerNow(taskID, TriggerOptions{}); err != nil {
+		t.Fatalf("Triggering the task should succeed, got %v", err)
+	}
+	result := <-results
+	mock.AssertNumberOfCalls(t, "CallNoArgs", 1)
+	if !result.Manual || result.Attempt != 1 {
+		t.Errorf("The result should be marked as manual, got %+v", result)
+	}
+	if info, _ := scheduler.Task(taskID); !info.NextRun.Equal(nextRun) || !info.LastRun.IsZero() {
+		t.Error("A manual run shouldn't affect the task's schedule")
+	}
+	entries, _ := scheduler.History(taskID, HistoryFilter{})
+	if len(entries) != 1 || !entries[0].Manual {
+		t.Error("The manual run should be recorded in the history")
+	}
+
+	if err := scheduler.TriggerNow(taskID, TriggerOptions{ResetSchedule: true}); err != nil {
+		t.Fatalf("Triggering the task should succeed, got %v", err)
+	}
+	<-results
+	info, _ := scheduler.Task(taskID)
+	if info.LastRun.IsZero() || !info.NextRun.Equal(info.LastRun.Add(time.Hour)) {
+		t.Errorf("The task's cadence should resume from the manual run, got %+v", info.Schedule)
+	}
+
+	if err := scheduler.TriggerNow("unknown", TriggerOptions{}); err == nil {
+		t.Error("Triggering an unknown task should fail")
+	}
+}
+
+func TestTriggerNowOneOff(t *testing.T) {
+	mock := task.CallbackMock{}
+	mock.On("CallNoArgs").Return()
+	results := make(chan task.Result, 1)
+	scheduler := New(storage.NewMemoryStorage())
+	scheduler.OnResult(func(result task.Result) {
+		results <- result
+	})
+	taskID, _ := scheduler.RunAfter(time.Hour, mock.CallNoArgs)
+
+	_ = scheduler.TriggerNow(taskID, TriggerOptions{ResetSchedule: true})
+	<-results
+	if _, found := scheduler.Task(taskID); !found {
+		t.Error("A one-off task should still be scheduled after a manual run")
+	}
+
+	scheduler.Stop()
+	if err := scheduler.TriggerNow(taskID, TriggerOptions{}); err != ErrStopped {
+		t.Error("Triggering a task should fail once the scheduler is stopped")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	mock := task.CallbackMock{}
+	mock.On("CallWithError", "Test").Return(nil)
+	results := make(chan task.Result, 1)
+	scheduler := New(storage.NewMemoryStorage())
+	scheduler.OnResult(func(result task.Result) {
+		results <- result
+	})
+
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, run RunInfo) task.Result {
+				calls = append(calls, name+" "+run.Params[0].(string))
+				return next(ctx, run)
+			}
+		}
+	}
+	scheduler.Use(trace("outer"), trace("inner"))
+	_, _ = scheduler.RunAt(time.Now(), mock.CallWithError, "Test")
+	scheduler.runPending()
+	if result := <-results; !result.Succeeded() {
+		t.Errorf("The run should succeed, got %v", result.Err)
+	}
+	mock.AssertNumberOfCalls(t, "CallWithError", 1)
+	if strings.Join(calls, ", ") != "outer Test, inner Test" {
+		t.Errorf("The middlewares should be called in order, got %v", calls)
+	}
+
+	// A middleware can prevent the function from being called, its panics are recovered
+	scheduler.OnPanic(nil)
+	scheduler.Use(func(next Handler) Handler {
+		return func(ctx context.Context, run RunInfo) task.Result {
+			panic("Denied")
+		}
+	})
+	_, _ = scheduler.RunAt(time.Now(), mock.CallWithError, "Test")
+	scheduler.runPending()
+	if result := <-results; !result.Panicked() || result.FuncName == "" {
+		t.Errorf("The middleware's panic should be reported in the result, got %+v", result)
+	}
+	mock.AssertNumberOfCalls(t, "CallWithError", 1)
+}
+
+func TestLifecycleHooks(t *testing.T) {
+	mock := task.CallbackMock{}
+	mock.On("CallNoArgs").Return()
+	mock.On("CallWithError", "Test").Return(errors.New("Failed"))
+
+	events := make(chan string, 10)
+	scheduler := New(storage.NewMemoryStorage())
+	scheduler.OnScheduled(func(info TaskInfo) {
+		events <- "scheduled " + info.FuncName
+	})
+	scheduler.OnStart(func(run RunInfo) {
+		events <- "start " + run.FuncName
+	})
+	scheduler.OnSuccess(func(result task.Result) {
+		events <- "success " + result.FuncName
+	})
+	scheduler.OnFailure(func(result task.Result) {
+		events <- "failure " + result.FuncName
+	})
+	scheduler.OnCancel(func(result task.Result) {
+		events <- "cancel " + result.FuncName
+	})
+	expect := func(want string) {
+		t.Helper()
+		select {
+		case event := <-events:
+			if !strings.HasPrefix(event, want) {
+				t.Errorf("Expected the %s hook, got %s", want, event)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("The %s hook wasn't called", want)
+		}
+	}
+
+	_, _ = scheduler.RunAt(time.Now(), mock.CallNoArgs)
+	expect("scheduled")
+	scheduler.runPending()
+	expect("start")
+	expect("success")
+
+	_, _ = scheduler.RunAt(time.Now(), mock.CallWithError, "Test")
+	expect("scheduled")
+	scheduler.runPending()
+	expect("start")
+	expect("failure")
+
+	taskID, _ := scheduler.RunAt(time.Now(), func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	expect("scheduled")
+	scheduler.runPending()
+	expect("start")
+	_ = scheduler.Cancel(taskID)
+	expect("cancel")
+}
+
+func TestHookPanicsAreRecovered(t *testing.T) {
+	mock := task.CallbackMock{}
+	mock.On("CallNoArgs").Return()
+
+	errs := make(chan error, 10)
+	results := make(chan task.Result, 1)
+	scheduler := New(storage.NewMemoryStorage(), WithErrorHandler(func(err error) {
+		errs <- err
+	}))
+	scheduler.OnScheduled(func(TaskInfo) {
+		panic("OnScheduled")
+	})
+	scheduler.OnStart(func(RunInfo) {
+		panic("OnStart")
+	})
+	scheduler.OnSuccess(func(task.Result) {
+		panic("OnSuccess")
+	})
+	scheduler.OnResult(func(task.Result) {
+		panic("OnResult")
+	})
+	scheduler.OnResult(func(result task.Result) {
+		results <- result
+	})
+
+	_, _ = scheduler.RunAt(time.Now(), mock.CallNoArgs)
+	scheduler.runPending()
+	select {
+	case result := <-results:
+		if !result.Succeeded() {
+			t.Errorf("The run should succeed despite the hooks, got %v", result.Err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The remaining handlers should be called after a hook panicked")
+	}
+	for _, hook := range []string{"OnScheduled hook", "OnStart hook", "OnSuccess hook", "OnResult handler"} {
+		err := <-errs
+		if panicErr, ok := err.(*HookPanicError); !ok || panicErr.Hook != hook {
+			t.Errorf("The panic of the %s should be reported, got %v", hook, err)
+		}
+	}
+	if err := scheduler.Shutdown(context.Background()); err != nil {
+		t.Error("The execution should be complete, got", err)
+	}
+}
+
+func TestOnMisfire(t *testing.T) {
+	mock := task.CallbackMock{}
+	mock.On("CallNoArgs").Return()
+	misfires := make(chan task.MisfirePolicy, 1)
+	scheduler := New(storage.NewMemoryStorage())
+	scheduler.OnMisfire(func(run RunInfo, policy task.MisfirePolicy) {
+		misfires <- policy
+	})
+
+	_, _ = scheduler.RunAt(time.Now(), mock.CallNoArgs)
+	scheduler.runPending()
+	taskID, _ := scheduler.RunEvery(time.Minute, mock.CallNoArgs)
+	recurring := scheduler.tasks[taskID]
+	recurring.NextRun = time.Now().Add(-10 * time.Minute)
+	scheduler.queue.schedule(taskID, recurring)
+	scheduler.runPending()
+
+	select {
+	case policy := <-misfires:
+		if policy != task.MisfireFireOnceNow {
+			t.Errorf("The applied misfire policy should be reported, got %v", policy)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The misfire hook wasn't called")
+	}
+	select {
+	case <-misfires:
+		t.Error("Only the late run should be reported as misfired")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
 func TestCancelTask(t *testing.T) {
 	scheduler := New(storage.NewNoOpStorage())
 	mock := task.CallbackMock{}
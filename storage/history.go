@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// HistoryRecord is a struct which is used to transfer the record of an execution from/to history stores.
+// Like TaskAttributes, all fields are strings. Times are formatted in UTC with a fixed width,
+// so that comparing them as strings compares the times.
+type HistoryRecord struct {
+	TaskHash    string
+	Name        string
+	ScheduledAt string
+	StartedAt   string
+	FinishedAt  string
+	Duration    string
+	Outcome     string
+	Error       string
+	Attempt     string
+	NodeID      string
+	// Manual is "1" for runs which were triggered manually.
+	Manual string
+}
+
+// HistoryQuery selects the records returned by a history store.
+type HistoryQuery struct {
+	// TaskHash selects the records of a single task, the records of all tasks are returned when empty.
+	TaskHash string
+	// Since and Until select the executions which started in [Since, Until), they are ignored when empty.
+	Since string
+	Until string
+	// Outcome selects the executions which ended with the outcome, it's ignored when empty.
+	Outcome string
+	// Limit is the maximum number of records returned, zero means no limit.
+	Limit int
+}
+
+// HistoryStore is the interface to implement when adding custom execution history storage.
+// Records are fetched most recent first.
+type HistoryStore interface {
+	AddRecord(HistoryRecord) error
+	FetchRecords(HistoryQuery) ([]HistoryRecord, error)
+	// PruneRecords removes the records of the executions which started before the given time.
+	PruneRecords(before string) error
+}
+
+// matches reports whether the record is selected by the query, the limit is not taken into account.
+func (query HistoryQuery) matches(record HistoryRecord) bool {
+	return (query.TaskHash == "" || record.TaskHash == query.TaskHash) &&
+		(query.Since == "" || record.StartedAt >= query.Since) &&
+		(query.Until == "" || record.StartedAt < query.Until) &&
+		(query.Outcome == "" || record.Outcome == query.Outcome)
+}
+
+// selectRecords returns the records selected by the query, most recent first.
+func selectRecords(records []HistoryRecord, query HistoryQuery) []HistoryRecord {
+	var selected []HistoryRecord
+	for _, record := range records {
+		if query.matches(record) {
+			selected = append(selected, record)
+		}
+	}
+	sort.SliceStable(selected, func(i, j int) bool {
+		return selected[i].StartedAt > selected[j].StartedAt
+	})
+	if query.Limit > 0 && len(selected) > query.Limit {
+		selected = selected[:query.Limit]
+	}
+	return selected
+}
+
+// historySQL builds the statement selecting the records of the query from the task_history table.
+// placeholder returns the placeholder of the n-th argument, which differs between SQL dialects.
+func historySQL(query HistoryQuery, placeholder func(n int) string) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, arg string) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, placeholder(len(args))))
+	}
+	if query.TaskHash != "" {
+		where("task_hash=%s", query.TaskHash)
+	}
+	if query.Since != "" {
+		where("started_at>=%s", query.Since)
+	}
+	if query.Until != "" {
+		where("started_at<%s", query.Until)
+	}
+	if query.Outcome != "" {
+		where("outcome=%s", query.Outcome)
+	}
+
+	stmt := `
+        SELECT task_hash, name, scheduled_at, started_at, finished_at, duration, outcome, error, attempt, node_id,
+            COALESCE(manual, '')
+        FROM task_history`
+	if len(conditions) > 0 {
+		stmt += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	stmt += " ORDER BY started_at DESC"
+	if query.Limit > 0 {
+		stmt += fmt.Sprintf(" LIMIT %d", query.Limit)
+	}
+	return stmt, args
+}
+
+// scanRecords reads the records selected by a statement built by historySQL.
+func scanRecords(rows *sql.Rows) ([]HistoryRecord, error) {
+	defer rows.Close()
+
+	var records []HistoryRecord
+	for rows.Next() {
+		record := HistoryRecord{}
+		err := rows.Scan(&record.TaskHash, &record.Name, &record.ScheduledAt, &record.StartedAt, &record.FinishedAt,
+			&record.Duration, &record.Outcome, &record.Error, &record.Attempt, &record.NodeID, &record.Manual)
+		if err != nil {
+			return []HistoryRecord{}, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
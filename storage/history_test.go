@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var sampleRecords = []HistoryRecord{
+	{TaskHash: "A", StartedAt: "2018-09-30T18:00:00.000000000Z", Outcome: "succeeded"},
+	{TaskHash: "B", StartedAt: "2018-09-30T18:00:05.000000000Z", Outcome: "failed"},
+	{TaskHash: "A", StartedAt: "2018-09-30T18:00:10.000000000Z", Outcome: "failed"},
+}
+
+// Tests selecting records in memory
+func TestSelectRecords(t *testing.T) {
+	records := selectRecords(sampleRecords, HistoryQuery{})
+	require.Len(t, records, 3)
+	require.Equal(t, sampleRecords[2], records[0])
+
+	records = selectRecords(sampleRecords, HistoryQuery{TaskHash: "A", Outcome: "failed"})
+	require.Equal(t, []HistoryRecord{sampleRecords[2]}, records)
+
+	records = selectRecords(sampleRecords, HistoryQuery{Since: sampleRecords[1].StartedAt, Until: sampleRecords[2].StartedAt})
+	require.Equal(t, []HistoryRecord{sampleRecords[1]}, records)
+
+	records = selectRecords(sampleRecords, HistoryQuery{Limit: 1})
+	require.Equal(t, []HistoryRecord{sampleRecords[2]}, records)
+}
+
+// Tests building the SQL statement selecting records
+func TestHistorySQL(t *testing.T) {
+	stmt, args := historySQL(HistoryQuery{TaskHash: "A", Since: "2018", Limit: 10}, func(n int) string {
+		return fmt.Sprintf("$%d", n)
+	})
+	require.Contains(t, stmt, "WHERE task_hash=$1 AND started_at>=$2 ORDER BY started_at DESC LIMIT 10")
+	require.Equal(t, []interface{}{"A", "2018"}, args)
+}
+
+// Tests pruning records of the memory store
+func TestMemoryPruneRecords(t *testing.T) {
+	store := NewMemoryStorage()
+	for _, record := range sampleRecords {
+		require.NoError(t, store.AddRecord(record))
+	}
+	require.NoError(t, store.PruneRecords(sampleRecords[1].StartedAt))
+
+	records, err := store.FetchRecords(HistoryQuery{})
+	require.NoError(t, err)
+	require.Equal(t, []HistoryRecord{sampleRecords[2], sampleRecords[1]}, records)
+}
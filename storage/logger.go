@@ -0,0 +1,20 @@
+package storage
+
+import "log/slog"
+
+// Logger is used by the stores and the scheduler to report what happens in the background.
+// Messages are followed by alternating keys and values, a *slog.Logger satisfies it.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// loggerOrDefault returns the logger, or the default slog logger when it's nil.
+func loggerOrDefault(logger Logger) Logger {
+	if logger == nil {
+		return slog.Default()
+	}
+	return logger
+}
@@ -1,8 +1,12 @@
 package storage
 
+import "sync"
+
 // MemoryStorage is a memory task store
 type MemoryStorage struct {
-	tasks []TaskAttributes
+	mu      sync.Mutex
+	tasks   []TaskAttributes
+	history []HistoryRecord
 }
 
 // NewMemoryStorage returns an instance of MemoryStorage.
@@ -12,17 +16,41 @@ func NewMemoryStorage() *MemoryStorage {
 
 // Add adds a task to the memory store.
 func (memStore *MemoryStorage) Add(task TaskAttributes) error {
+	memStore.mu.Lock()
+	defer memStore.mu.Unlock()
+
 	memStore.tasks = append(memStore.tasks, task)
 	return nil
 }
 
 // Fetch will return all tasks stored.
 func (memStore *MemoryStorage) Fetch() ([]TaskAttributes, error) {
-	return memStore.tasks, nil
+	memStore.mu.Lock()
+	defer memStore.mu.Unlock()
+
+	return append([]TaskAttributes(nil), memStore.tasks...), nil
+}
+
+// Update replaces the task with the same hash, or adds it when it's not stored.
+func (memStore *MemoryStorage) Update(task TaskAttributes) error {
+	memStore.mu.Lock()
+	defer memStore.mu.Unlock()
+
+	for i, existingTask := range memStore.tasks {
+		if task.Hash == existingTask.Hash {
+			memStore.tasks[i] = task
+			return nil
+		}
+	}
+	memStore.tasks = append(memStore.tasks, task)
+	return nil
 }
 
 // Remove will remove task from store
 func (memStore *MemoryStorage) Remove(task TaskAttributes) error {
+	memStore.mu.Lock()
+	defer memStore.mu.Unlock()
+
 	var newTasks []TaskAttributes
 	for _, existingTask := range memStore.tasks {
 		if task.Hash == existingTask.Hash {
@@ -34,6 +62,38 @@ func (memStore *MemoryStorage) Remove(task TaskAttributes) error {
 	return nil
 }
 
+// AddRecord adds the record of an execution to the memory store.
+func (memStore *MemoryStorage) AddRecord(record HistoryRecord) error {
+	memStore.mu.Lock()
+	defer memStore.mu.Unlock()
+
+	memStore.history = append(memStore.history, record)
+	return nil
+}
+
+// FetchRecords returns the records selected by the query, most recent first.
+func (memStore *MemoryStorage) FetchRecords(query HistoryQuery) ([]HistoryRecord, error) {
+	memStore.mu.Lock()
+	defer memStore.mu.Unlock()
+
+	return selectRecords(memStore.history, query), nil
+}
+
+// PruneRecords removes the records of the executions which started before the given time.
+func (memStore *MemoryStorage) PruneRecords(before string) error {
+	memStore.mu.Lock()
+	defer memStore.mu.Unlock()
+
+	var history []HistoryRecord
+	for _, record := range memStore.history {
+		if record.StartedAt >= before {
+			history = append(history, record)
+		}
+	}
+	memStore.history = history
+	return nil
+}
+
 func (memStore *MemoryStorage) Close() error {
 	return nil
 }
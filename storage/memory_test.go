@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Tests updating tasks of the memory store
+func TestMemoryUpdate(t *testing.T) {
+	store := NewMemoryStorage()
+	require.NoError(t, store.Add(sampleTask))
+
+	updated := sampleTask
+	updated.NextRun = "2018-09-30T20:00:10+02:00"
+	require.NoError(t, store.Update(updated))
+
+	added := sampleTask
+	added.Hash = "E"
+	require.NoError(t, store.Update(added))
+
+	tasks, err := store.Fetch()
+	require.NoError(t, err)
+	require.Equal(t, []TaskAttributes{updated, added}, tasks)
+}
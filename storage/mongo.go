@@ -6,30 +6,37 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
 
 	"github.com/mongodb/mongo-go-driver/mongo"
+	"github.com/mongodb/mongo-go-driver/mongo/options"
 	"github.com/mongodb/mongo-go-driver/x/bsonx"
 )
 
 const COLLECTION_NAME string = "task_store"
 
+// HISTORY_COLLECTION_NAME is the collection holding the records of the executions.
+const HISTORY_COLLECTION_NAME string = "task_history"
+
 // MongoDBConfig is the config structure holding information about mongo db.
 type MongoDBConfig struct {
 	ConnectionUrl string
 	Db            string
+	// Logger reports the errors of the store, the default slog logger is used when it's nil.
+	Logger Logger
 }
 
 // MongoDBStorage is the structure responsible for handling mongo storage.
 type MongoDBStorage struct {
 	config MongoDBConfig
 	client *mongo.Client
+	logger Logger
 }
 
 // NewMongoDBStorage returns a new instance of MongoDBStorage.
 func NewMongoDBStorage(config MongoDBConfig) *MongoDBStorage {
 	return &MongoDBStorage{
 		config: config,
+		logger: loggerOrDefault(config.Logger),
 	}
 }
 
@@ -59,7 +66,7 @@ func (mongodb *MongoDBStorage) Initialize() error {
 		Database(mongodb.config.Db).Collection(COLLECTION_NAME)
 
 	if task_store == nil {
-		log.Printf("could not initialize collection")
+		mongodb.logger.Error("Couldn't initialize the collection", "store", "mongodb", "collection", COLLECTION_NAME)
 		return errors.New("mongo error")
 	}
 
@@ -83,16 +90,7 @@ func (mongodb MongoDBStorage) Add(task TaskAttributes) error {
 	}
 
 	if res == 0 {
-		res, err := task_store.InsertOne(context.Background(),
-			map[string]string{
-				"name":         task.Name,
-				"params":       task.Params,
-				"duration":     task.Duration,
-				"last_run":     task.LastRun,
-				"next_run":     task.NextRun,
-				"is_recurring": task.IsRecurring,
-				"hash":         task.Hash,
-			})
+		res, err := task_store.InsertOne(context.Background(), taskDocument(task))
 		if res == nil {
 			return errors.New("element not inserted")
 		}
@@ -101,6 +99,21 @@ func (mongodb MongoDBStorage) Add(task TaskAttributes) error {
 	return nil
 }
 
+// Update replaces the stored task with the same hash, the task is inserted when it's not stored.
+func (mongodb MongoDBStorage) Update(task TaskAttributes) error {
+	task_store := mongodb.client.Database(mongodb.config.Db).Collection(COLLECTION_NAME)
+
+	if task_store == nil {
+		return errors.New("could not get collection")
+	}
+
+	filter := bsonx.Doc{{"hash", bsonx.String(task.Hash)}}
+	_, err := task_store.ReplaceOne(context.Background(), filter, taskDocument(task),
+		options.Replace().SetUpsert(true))
+
+	return err
+}
+
 // Remove will delete the task from storage.
 func (mongodb MongoDBStorage) Remove(task TaskAttributes) error {
 	task_store := mongodb.client.Database(mongodb.config.Db).Collection(COLLECTION_NAME)
@@ -117,12 +130,33 @@ func (mongodb MongoDBStorage) Remove(task TaskAttributes) error {
 	return err
 }
 
+// taskDocument returns the document storing the task.
+func taskDocument(task TaskAttributes) map[string]string {
+	return map[string]string{
+		"name":         task.Name,
+		"params":       task.Params,
+		"duration":     task.Duration,
+		"last_run":     task.LastRun,
+		"next_run":     task.NextRun,
+		"is_recurring": task.IsRecurring,
+		"cron":         task.Cron,
+		"location":     task.Location,
+		"retry":        task.Retry,
+		"attempt":      task.Attempt,
+		"hash":         task.Hash,
+		"task_id":      task.ID,
+		"paused":       task.Paused,
+		"modified":     task.Modified,
+		"options":      task.Options,
+	}
+}
+
 // Fetch will return the list of all stored tasks.
 func (mongodb MongoDBStorage) Fetch() ([]TaskAttributes, error) {
 	task_store := mongodb.client.Database(mongodb.config.Db).Collection(COLLECTION_NAME)
 
 	if task_store == nil {
-		log.Println("taskstore empty")
+		mongodb.logger.Error("Couldn't get the collection", "store", "mongodb", "collection", COLLECTION_NAME)
 		return nil, errors.New("could not get collection")
 	}
 
@@ -140,10 +174,20 @@ func (mongodb MongoDBStorage) Fetch() ([]TaskAttributes, error) {
 		var elem bsonx.Doc
 		err := cur.Decode(&elem)
 		if err != nil {
-			log.Println(err.Error())
+			mongodb.logger.Error("Couldn't decode a stored task", "store", "mongodb", "error", err)
 			return nil, err
 		}
 
+		// Documents stored by older versions lack the newer fields
+		cron, _ := elem.Lookup("cron").StringValueOK()
+		location, _ := elem.Lookup("location").StringValueOK()
+		retry, _ := elem.Lookup("retry").StringValueOK()
+		attempt, _ := elem.Lookup("attempt").StringValueOK()
+		id, _ := elem.Lookup("task_id").StringValueOK()
+		paused, _ := elem.Lookup("paused").StringValueOK()
+		modified, _ := elem.Lookup("modified").StringValueOK()
+		taskOptions, _ := elem.Lookup("options").StringValueOK()
+
 		task := TaskAttributes{
 			Name:        elem.Lookup("name").StringValue(),
 			Params:      elem.Lookup("params").StringValue(),
@@ -151,10 +195,129 @@ func (mongodb MongoDBStorage) Fetch() ([]TaskAttributes, error) {
 			NextRun:     elem.Lookup("next_run").StringValue(),
 			Duration:    elem.Lookup("duration").StringValue(),
 			IsRecurring: elem.Lookup("is_recurring").StringValue(),
+			Cron:        cron,
+			Location:    location,
+			Retry:       retry,
+			Attempt:     attempt,
 			Hash:        elem.Lookup("hash").StringValue(),
+			ID:          id,
+			Paused:      paused,
+			Modified:    modified,
+			Options:     taskOptions,
 		}
 
 		tasks = append(tasks, task)
 	}
 	return tasks, nil
 }
+
+// AddRecord stores the record of an execution to mongo.
+func (mongodb MongoDBStorage) AddRecord(record HistoryRecord) error {
+	task_history := mongodb.client.Database(mongodb.config.Db).Collection(HISTORY_COLLECTION_NAME)
+
+	if task_history == nil {
+		return errors.New("could not get collection")
+	}
+
+	res, err := task_history.InsertOne(context.Background(),
+		map[string]string{
+			"task_hash":    record.TaskHash,
+			"name":         record.Name,
+			"scheduled_at": record.ScheduledAt,
+			"started_at":   record.StartedAt,
+			"finished_at":  record.FinishedAt,
+			"duration":     record.Duration,
+			"outcome":      record.Outcome,
+			"error":        record.Error,
+			"attempt":      record.Attempt,
+			"node_id":      record.NodeID,
+			"manual":       record.Manual,
+		})
+	if res == nil {
+		return errors.New("element not inserted")
+	}
+	return err
+}
+
+// FetchRecords returns the records selected by the query, most recent first.
+func (mongodb MongoDBStorage) FetchRecords(query HistoryQuery) ([]HistoryRecord, error) {
+	task_history := mongodb.client.Database(mongodb.config.Db).Collection(HISTORY_COLLECTION_NAME)
+
+	if task_history == nil {
+		return nil, errors.New("could not get collection")
+	}
+
+	findOptions := options.Find().SetSort(bsonx.Doc{{"started_at", bsonx.Int32(-1)}})
+	if query.Limit > 0 {
+		findOptions.SetLimit(int64(query.Limit))
+	}
+	cur, err := task_history.Find(context.Background(), historyFilter(query), findOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	defer cur.Close(context.Background())
+
+	var records []HistoryRecord
+	for cur.Next(context.Background()) {
+		var elem bsonx.Doc
+		err := cur.Decode(&elem)
+		if err != nil {
+			return nil, err
+		}
+
+		// Records added by older versions lack the newer fields
+		manual, _ := elem.Lookup("manual").StringValueOK()
+
+		records = append(records, HistoryRecord{
+			TaskHash:    elem.Lookup("task_hash").StringValue(),
+			Name:        elem.Lookup("name").StringValue(),
+			ScheduledAt: elem.Lookup("scheduled_at").StringValue(),
+			StartedAt:   elem.Lookup("started_at").StringValue(),
+			FinishedAt:  elem.Lookup("finished_at").StringValue(),
+			Duration:    elem.Lookup("duration").StringValue(),
+			Outcome:     elem.Lookup("outcome").StringValue(),
+			Error:       elem.Lookup("error").StringValue(),
+			Attempt:     elem.Lookup("attempt").StringValue(),
+			NodeID:      elem.Lookup("node_id").StringValue(),
+			Manual:      manual,
+		})
+	}
+	return records, cur.Err()
+}
+
+// historyFilter builds the filter selecting the records of the query, like historySQL does for SQL stores.
+func historyFilter(query HistoryQuery) bsonx.Doc {
+	filter := bsonx.Doc{}
+	if query.TaskHash != "" {
+		filter = append(filter, bsonx.Elem{"task_hash", bsonx.String(query.TaskHash)})
+	}
+	startedAt := bsonx.Doc{}
+	if query.Since != "" {
+		startedAt = append(startedAt, bsonx.Elem{"$gte", bsonx.String(query.Since)})
+	}
+	if query.Until != "" {
+		startedAt = append(startedAt, bsonx.Elem{"$lt", bsonx.String(query.Until)})
+	}
+	if len(startedAt) > 0 {
+		filter = append(filter, bsonx.Elem{"started_at", bsonx.Document(startedAt)})
+	}
+	if query.Outcome != "" {
+		filter = append(filter, bsonx.Elem{"outcome", bsonx.String(query.Outcome)})
+	}
+	return filter
+}
+
+// PruneRecords deletes the records of the executions which started before the given time.
+func (mongodb MongoDBStorage) PruneRecords(before string) error {
+	task_history := mongodb.client.Database(mongodb.config.Db).Collection(HISTORY_COLLECTION_NAME)
+
+	if task_history == nil {
+		return errors.New("could not get collection")
+	}
+
+	filter := bsonx.Doc{{"started_at", bsonx.Document(bsonx.Doc{{"$lt", bsonx.String(before)}})}}
+	_, err := task_history.DeleteMany(context.Background(), filter)
+
+	return err
+}
@@ -96,6 +96,18 @@ func TestRemove(t *testing.T) {
 	require.NoError(t, err)
 }
 
+// Tests the filter selecting history records
+func TestHistoryFilter(t *testing.T) {
+	require.Equal(t, bsonx.Doc{}, historyFilter(HistoryQuery{Limit: 10}))
+
+	filter := historyFilter(HistoryQuery{TaskHash: "A", Since: "2018", Until: "2019", Outcome: "failed"})
+	require.Equal(t, bsonx.Doc{
+		{"task_hash", bsonx.String("A")},
+		{"started_at", bsonx.Document(bsonx.Doc{{"$gte", bsonx.String("2018")}, {"$lt", bsonx.String("2019")}})},
+		{"outcome", bsonx.String("failed")},
+	}, filter)
+}
+
 // Test closing
 func TestClose(t *testing.T) {
 	mongoStorage.Init(mongoConfig, t)
@@ -19,11 +19,31 @@ func (noop NoOpStorage) Fetch() ([]TaskAttributes, error) {
 	return []TaskAttributes{}, nil
 }
 
+// Update does nothing
+func (noop NoOpStorage) Update(task TaskAttributes) error {
+	return nil
+}
+
 // Remove does nothing
 func (noop NoOpStorage) Remove(task TaskAttributes) error {
 	return nil
 }
 
+// AddRecord does nothing
+func (noop NoOpStorage) AddRecord(record HistoryRecord) error {
+	return nil
+}
+
+// FetchRecords returns an empty list of records
+func (noop NoOpStorage) FetchRecords(query HistoryQuery) ([]HistoryRecord, error) {
+	return []HistoryRecord{}, nil
+}
+
+// PruneRecords does nothing
+func (noop NoOpStorage) PruneRecords(before string) error {
+	return nil
+}
+
 func (noop NoOpStorage) Close() error {
 	return nil
 }
@@ -3,13 +3,14 @@ package storage
 import (
 	"database/sql"
 	"fmt"
-	"log"
 
 	_ "github.com/lib/pq"
 )
 
 type PostgresDBConfig struct {
 	DbURL string
+	// Logger reports the errors of the store, the default slog logger is used when it's nil.
+	Logger Logger
 }
 
 type postgresStorage struct {
@@ -21,16 +22,17 @@ type postgresStorage struct {
 func NewPostgresStorage(config PostgresDBConfig) (postgres *postgresStorage, err error) {
 	// TODO should connect and initialize as well.
 	postgres = &postgresStorage{config: config}
+	logger := loggerOrDefault(config.Logger)
 	// tyr to connect to givenDB.
 	err = postgres.connect()
 	if err != nil {
-		log.Printf("Unable to connect to DB : %s, error : %v", config.DbURL, err)
+		logger.Error("Unable to connect to the database", "store", "postgres", "error", err)
 		return nil, err
 	}
 	// lets initialize the DB as needed.
 	err = postgres.initialize()
 	if err != nil {
-		log.Printf("Couldn't initialize the DB, error : %v", err)
+		logger.Error("Couldn't initialize the database", "store", "postgres", "error", err)
 		return nil, err
 	}
 	return postgres, nil
@@ -56,14 +58,42 @@ func (postgres *postgresStorage) initialize() (err error) {
 		last_run text,
 		next_run text,
 		is_recurring text,
-		hash text
+		cron text,
+		location text,
+		retry text,
+		attempt text,
+		hash text,
+		task_id text,
+		paused text,
+		modified text,
+		options text
 	);
+	ALTER TABLE task_store ADD COLUMN IF NOT EXISTS cron text;
+	ALTER TABLE task_store ADD COLUMN IF NOT EXISTS location text;
+	ALTER TABLE task_store ADD COLUMN IF NOT EXISTS retry text;
+	ALTER TABLE task_store ADD COLUMN IF NOT EXISTS attempt text;
+	ALTER TABLE task_store ADD COLUMN IF NOT EXISTS task_id text;
+	ALTER TABLE task_store ADD COLUMN IF NOT EXISTS paused text;
+	ALTER TABLE task_store ADD COLUMN IF NOT EXISTS modified text;
+	ALTER TABLE task_store ADD COLUMN IF NOT EXISTS options text;
+	CREATE TABLE IF NOT EXISTS task_history (
+		id SERIAL NOT NULL PRIMARY KEY,
+		task_hash text,
+		name text,
+		scheduled_at text,
+		started_at text,
+		finished_at text,
+		duration text,
+		outcome text,
+		error text,
+		attempt text,
+		node_id text,
+		manual text
+	);
+	ALTER TABLE task_history ADD COLUMN IF NOT EXISTS manual text;
+	CREATE INDEX IF NOT EXISTS task_history_started_at ON task_history (task_hash, started_at);
 	`
 	_, err = postgres.db.Exec(stmt)
-	if err != nil {
-		log.Printf("Error while initializing: %q - %+v", stmt, err)
-		return
-	}
 	return
 }
 
@@ -74,11 +104,9 @@ func (postgres *postgresStorage) Close() error {
 func (postgres *postgresStorage) Add(task TaskAttributes) error {
 	// should add a task to the database `task_store` table
 	var count int
-	rows, err := postgres.db.Query("SELECT count(*) FROM task_store WHERE hash=($1) ;", task.Hash)
-	defer rows.Close()
-	if err == nil {
-		rows.Next()
-		_ = rows.Scan(&count)
+	err := postgres.db.QueryRow("SELECT count(*) FROM task_store WHERE hash=($1) ;", task.Hash).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("Error while checking whether the task is stored: %s", err)
 	}
 
 	if count == 0 {
@@ -90,11 +118,14 @@ func (postgres *postgresStorage) Add(task TaskAttributes) error {
 func (postgres *postgresStorage) Fetch() ([]TaskAttributes, error) {
 	// read all the rows task_store table.
 	rows, err := postgres.db.Query(`
-        SELECT name, params, duration, last_run, next_run, is_recurring
+        SELECT name, params, duration, last_run, next_run, is_recurring, COALESCE(cron, ''),
+            COALESCE(location, ''), COALESCE(retry, ''), COALESCE(attempt, ''), COALESCE(task_id, ''),
+            COALESCE(paused, ''), COALESCE(modified, ''), COALESCE(options, ''),
+            COALESCE(hash, '')
         FROM task_store ;`)
 
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("Error while fetching tasks: %s", err)
 	}
 
 	defer rows.Close()
@@ -104,7 +135,9 @@ func (postgres *postgresStorage) Fetch() ([]TaskAttributes, error) {
 	for rows.Next() {
 		// var task TaskAttributes
 		task := TaskAttributes{}
-		err = rows.Scan(&task.Name, &task.Params, &task.Duration, &task.LastRun, &task.NextRun, &task.IsRecurring)
+		err = rows.Scan(&task.Name, &task.Params, &task.Duration, &task.LastRun, &task.NextRun, &task.IsRecurring,
+			&task.Cron, &task.Location, &task.Retry, &task.Attempt, &task.ID, &task.Paused,
+			&task.Modified, &task.Options, &task.Hash)
 		if err != nil {
 			return []TaskAttributes{}, err
 		}
@@ -112,11 +145,72 @@ func (postgres *postgresStorage) Fetch() ([]TaskAttributes, error) {
 	}
 	err = rows.Err()
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("Error while fetching tasks: %s", err)
 	}
 	return tasks, nil
 }
 
+// Update replaces the stored task with the same hash in a single transaction, the task is inserted
+// when it's not stored.
+func (postgres *postgresStorage) Update(task TaskAttributes) error {
+	tx, err := postgres.db.Begin()
+	if err != nil {
+		return fmt.Errorf("Error while starting update task transaction: %+v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+        UPDATE task_store SET name=($1), params=($2), duration=($3), last_run=($4), next_run=($5), is_recurring=($6),
+            cron=($7), location=($8), retry=($9), attempt=($10), task_id=($12), paused=($13),
+            modified=($14), options=($15)
+        WHERE hash=($11) ;`,
+		task.Name,
+		task.Params,
+		task.Duration,
+		task.LastRun,
+		task.NextRun,
+		task.IsRecurring,
+		task.Cron,
+		task.Location,
+		task.Retry,
+		task.Attempt,
+		task.Hash,
+		task.ID,
+		task.Paused,
+		task.Modified,
+		task.Options,
+	)
+	if err != nil {
+		return fmt.Errorf("Error while updating task: %+v", err)
+	}
+	if updated, err := result.RowsAffected(); err == nil && updated == 0 {
+		_, err = tx.Exec(`
+        INSERT INTO task_store(name, params, duration, last_run, next_run, is_recurring, cron, location, retry, attempt, hash, task_id,
+            paused, modified, options)
+        VALUES(($1), ($2), ($3), ($4), ($5), ($6), ($7), ($8), ($9), ($10), ($11), ($12), ($13), ($14), ($15));`,
+			task.Name,
+			task.Params,
+			task.Duration,
+			task.LastRun,
+			task.NextRun,
+			task.IsRecurring,
+			task.Cron,
+			task.Location,
+			task.Retry,
+			task.Attempt,
+			task.Hash,
+			task.ID,
+			task.Paused,
+			task.Modified,
+			task.Options,
+		)
+		if err != nil {
+			return fmt.Errorf("Error while inserting task: %+v", err)
+		}
+	}
+	return tx.Commit()
+}
+
 func (postgres *postgresStorage) Remove(task TaskAttributes) error {
 	// should delete the entry from `task_stor` table.
 	stmt, err := postgres.db.Prepare(`DELETE FROM task_store WHERE hash=($1) ;`)
@@ -139,8 +233,9 @@ func (postgres *postgresStorage) Remove(task TaskAttributes) error {
 
 func (postgres *postgresStorage) insert(task TaskAttributes) (err error) {
 	stmt, err := postgres.db.Prepare(`
-        INSERT INTO task_store(name, params, duration, last_run, next_run, is_recurring, hash)
-        VALUES(($1), ($2), ($3), ($4), ($5), ($6), ($7));`)
+        INSERT INTO task_store(name, params, duration, last_run, next_run, is_recurring, cron, location, retry, attempt, hash, task_id,
+            paused, modified, options)
+        VALUES(($1), ($2), ($3), ($4), ($5), ($6), ($7), ($8), ($9), ($10), ($11), ($12), ($13), ($14), ($15));`)
 
 	if err != nil {
 		return fmt.Errorf("Error while pareparing insert task statement: %s", err)
@@ -155,7 +250,15 @@ func (postgres *postgresStorage) insert(task TaskAttributes) (err error) {
 		task.LastRun,
 		task.NextRun,
 		task.IsRecurring,
+		task.Cron,
+		task.Location,
+		task.Retry,
+		task.Attempt,
 		task.Hash,
+		task.ID,
+		task.Paused,
+		task.Modified,
+		task.Options,
 	)
 	if err != nil {
 		return fmt.Errorf("Error while inserting task: %s", err)
@@ -163,3 +266,45 @@ func (postgres *postgresStorage) insert(task TaskAttributes) (err error) {
 
 	return nil
 }
+
+func (postgres *postgresStorage) AddRecord(record HistoryRecord) error {
+	_, err := postgres.db.Exec(`
+        INSERT INTO task_history(task_hash, name, scheduled_at, started_at, finished_at, duration, outcome, error, attempt, node_id,
+            manual)
+        VALUES(($1), ($2), ($3), ($4), ($5), ($6), ($7), ($8), ($9), ($10), ($11));`,
+		record.TaskHash,
+		record.Name,
+		record.ScheduledAt,
+		record.StartedAt,
+		record.FinishedAt,
+		record.Duration,
+		record.Outcome,
+		record.Error,
+		record.Attempt,
+		record.NodeID,
+		record.Manual,
+	)
+	if err != nil {
+		return fmt.Errorf("Error while inserting history record: %+v", err)
+	}
+	return nil
+}
+
+func (postgres *postgresStorage) FetchRecords(query HistoryQuery) ([]HistoryRecord, error) {
+	stmt, args := historySQL(query, func(n int) string {
+		return fmt.Sprintf("($%d)", n)
+	})
+	rows, err := postgres.db.Query(stmt, args...)
+	if err != nil {
+		return []HistoryRecord{}, fmt.Errorf("Error while fetching history records: %+v", err)
+	}
+	return scanRecords(rows)
+}
+
+func (postgres *postgresStorage) PruneRecords(before string) error {
+	_, err := postgres.db.Exec(`DELETE FROM task_history WHERE started_at<($1) ;`, before)
+	if err != nil {
+		return fmt.Errorf("Error while pruning history records: %+v", err)
+	}
+	return nil
+}
@@ -5,7 +5,7 @@ package storage
 import (
 	"database/sql"
 	"fmt"
-	"log"
+	"strings"
 
 	// Import the sqlite3 driver
 	_ "github.com/mattn/go-sqlite3"
@@ -14,18 +14,22 @@ import (
 // Sqlite3Config is the config structure holding information about sqlite db.
 type Sqlite3Config struct {
 	DbName string
+	// Logger reports the errors of the store, the default slog logger is used when it's nil.
+	Logger Logger
 }
 
 // Sqlite3Storage is the structure responsible for handling sqlite3 storage.
 type Sqlite3Storage struct {
 	config Sqlite3Config
 	db     *sql.DB
+	logger Logger
 }
 
 // NewSqlite3Storage returns a new instance of Sqlite3Storage.
 func NewSqlite3Storage(config Sqlite3Config) Sqlite3Storage {
 	return Sqlite3Storage{
 		config: config,
+		logger: loggerOrDefault(config.Logger),
 	}
 }
 
@@ -56,12 +60,57 @@ func (sqlite *Sqlite3Storage) Initialize() error {
         last_run text,
         next_run text,
         is_recurring integer,
-        hash text
+        cron text,
+        location text,
+        retry text,
+        attempt integer,
+        hash text,
+        task_id text,
+        paused integer,
+        modified integer,
+        options text
     );
 	`
 	_, err := sqlite.db.Exec(sqlStmt)
 	if err != nil {
-		log.Printf("%q: %s\n", err, sqlStmt)
+		sqlite.logger.Error("Couldn't create the task table", "store", "sqlite3", "error", err)
+		return err
+	}
+
+	// Tables created by older versions lack the newer columns, add them in place.
+	for _, column := range []string{"cron text", "location text", "retry text", "attempt integer", "task_id text", "paused integer",
+		"modified integer", "options text"} {
+		_, err = sqlite.db.Exec("ALTER TABLE task_store ADD COLUMN " + column)
+		if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+			return err
+		}
+	}
+
+	sqlStmt = `
+    CREATE TABLE IF NOT EXISTS task_history (
+        id integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+        task_hash text,
+        name text,
+        scheduled_at text,
+        started_at text,
+        finished_at text,
+        duration text,
+        outcome text,
+        error text,
+        attempt text,
+        node_id text,
+        manual text
+    );
+    CREATE INDEX IF NOT EXISTS task_history_started_at ON task_history (task_hash, started_at);
+	`
+	_, err = sqlite.db.Exec(sqlStmt)
+	if err != nil {
+		sqlite.logger.Error("Couldn't create the history table", "store", "sqlite3", "error", err)
+		return err
+	}
+
+	_, err = sqlite.db.Exec("ALTER TABLE task_history ADD COLUMN manual text")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
 		return err
 	}
 	return nil
@@ -70,12 +119,10 @@ func (sqlite *Sqlite3Storage) Initialize() error {
 // Add stores the task to sqlite.
 func (sqlite Sqlite3Storage) Add(task TaskAttributes) error {
 	var count int
-	rows, err := sqlite.db.Query("SELECT count(*) FROM task_store WHERE hash=?", task.Hash)
-	if err == nil {
-		rows.Next()
-		_ = rows.Scan(&count)
+	err := sqlite.db.QueryRow("SELECT count(*) FROM task_store WHERE hash=?", task.Hash).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("Error while checking whether the task is stored: %s", err)
 	}
-	_ = rows.Close()
 
 	if count == 0 {
 		return sqlite.insert(task)
@@ -83,6 +130,66 @@ func (sqlite Sqlite3Storage) Add(task TaskAttributes) error {
 	return nil
 }
 
+// Update replaces the stored task with the same hash in a single transaction, the task is inserted
+// when it's not stored.
+func (sqlite Sqlite3Storage) Update(task TaskAttributes) error {
+	tx, err := sqlite.db.Begin()
+	if err != nil {
+		return fmt.Errorf("Error while starting update task transaction: %s", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+        UPDATE task_store SET name=?, params=?, duration=?, last_run=?, next_run=?, is_recurring=?, cron=?,
+            location=?, retry=?, attempt=?, task_id=?, paused=?, modified=?, options=?
+        WHERE hash=?`,
+		task.Name,
+		task.Params,
+		task.Duration,
+		task.LastRun,
+		task.NextRun,
+		task.IsRecurring,
+		task.Cron,
+		task.Location,
+		task.Retry,
+		task.Attempt,
+		task.ID,
+		task.Paused,
+		task.Modified,
+		task.Options,
+		task.Hash,
+	)
+	if err != nil {
+		return fmt.Errorf("Error while updating task: %s", err)
+	}
+	if updated, err := result.RowsAffected(); err == nil && updated == 0 {
+		_, err = tx.Exec(`
+        INSERT INTO task_store(name, params, duration, last_run, next_run, is_recurring, cron, location, retry, attempt, hash, task_id,
+            paused, modified, options)
+        VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			task.Name,
+			task.Params,
+			task.Duration,
+			task.LastRun,
+			task.NextRun,
+			task.IsRecurring,
+			task.Cron,
+			task.Location,
+			task.Retry,
+			task.Attempt,
+			task.Hash,
+			task.ID,
+			task.Paused,
+			task.Modified,
+			task.Options,
+		)
+		if err != nil {
+			return fmt.Errorf("Error while inserting task: %s", err)
+		}
+	}
+	return tx.Commit()
+}
+
 // Remove will delete the task from storage.
 func (sqlite Sqlite3Storage) Remove(task TaskAttributes) error {
 	stmt, err := sqlite.db.Prepare(`DELETE FROM task_store WHERE hash=?`)
@@ -106,11 +213,14 @@ func (sqlite Sqlite3Storage) Remove(task TaskAttributes) error {
 // Fetch will return the list of all stored tasks.
 func (sqlite Sqlite3Storage) Fetch() ([]TaskAttributes, error) {
 	rows, err := sqlite.db.Query(`
-        SELECT name, params, duration, last_run, next_run, is_recurring
+        SELECT name, params, duration, last_run, next_run, is_recurring, COALESCE(cron, ''),
+            COALESCE(location, ''), COALESCE(retry, ''), COALESCE(attempt, 0), COALESCE(task_id, ''),
+            COALESCE(paused, 0), COALESCE(modified, 0), COALESCE(options, ''),
+            COALESCE(hash, '')
         FROM task_store`)
 
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("Error while fetching tasks: %s", err)
 	}
 
 	defer rows.Close()
@@ -118,34 +228,45 @@ func (sqlite Sqlite3Storage) Fetch() ([]TaskAttributes, error) {
 	var tasks []TaskAttributes
 
 	for rows.Next() {
-		var name, params, lastRun, nextRun, duration, isRecurring string
-		err = rows.Scan(&name, &params, &duration, &lastRun, &nextRun, &isRecurring)
+		var name, params, lastRun, nextRun, duration, isRecurring, cron, location, retry, attempt, id, paused, modified, options, hash string
+		err = rows.Scan(&name, &params, &duration, &lastRun, &nextRun, &isRecurring, &cron, &location, &retry, &attempt, &id,
+			&paused, &modified, &options, &hash)
 		if err != nil {
 			return []TaskAttributes{}, err
 		}
 
 		task := TaskAttributes{
+			ID:          id,
 			Name:        name,
 			Params:      params,
 			LastRun:     lastRun,
 			NextRun:     nextRun,
 			Duration:    string(duration),
 			IsRecurring: string(isRecurring),
+			Cron:        cron,
+			Location:    location,
+			Retry:       retry,
+			Attempt:     attempt,
+			Paused:      paused,
+			Modified:    modified,
+			Options:     options,
+			Hash:        hash,
 		}
 
 		tasks = append(tasks, task)
 	}
 	err = rows.Err()
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("Error while fetching tasks: %s", err)
 	}
 	return tasks, nil
 }
 
 func (sqlite *Sqlite3Storage) insert(task TaskAttributes) error {
 	stmt, err := sqlite.db.Prepare(`
-        INSERT INTO task_store(name, params, duration, last_run, next_run, is_recurring, hash)
-        VALUES(?, ?, ?, ?, ?, ?, ?)`)
+        INSERT INTO task_store(name, params, duration, last_run, next_run, is_recurring, cron, location, retry, attempt, hash, task_id,
+            paused, modified, options)
+        VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
 
 	if err != nil {
 		return fmt.Errorf("Error while pareparing insert task statement: %s", err)
@@ -160,7 +281,15 @@ func (sqlite *Sqlite3Storage) insert(task TaskAttributes) error {
 		task.LastRun,
 		task.NextRun,
 		task.IsRecurring,
+		task.Cron,
+		task.Location,
+		task.Retry,
+		task.Attempt,
 		task.Hash,
+		task.ID,
+		task.Paused,
+		task.Modified,
+		task.Options,
 	)
 	if err != nil {
 		return fmt.Errorf("Error while inserting task: %s", err)
@@ -168,3 +297,48 @@ func (sqlite *Sqlite3Storage) insert(task TaskAttributes) error {
 
 	return nil
 }
+
+// AddRecord stores the record of an execution to sqlite.
+func (sqlite Sqlite3Storage) AddRecord(record HistoryRecord) error {
+	_, err := sqlite.db.Exec(`
+        INSERT INTO task_history(task_hash, name, scheduled_at, started_at, finished_at, duration, outcome, error, attempt, node_id,
+            manual)
+        VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		record.TaskHash,
+		record.Name,
+		record.ScheduledAt,
+		record.StartedAt,
+		record.FinishedAt,
+		record.Duration,
+		record.Outcome,
+		record.Error,
+		record.Attempt,
+		record.NodeID,
+		record.Manual,
+	)
+	if err != nil {
+		return fmt.Errorf("Error while inserting history record: %s", err)
+	}
+	return nil
+}
+
+// FetchRecords returns the records selected by the query, most recent first.
+func (sqlite Sqlite3Storage) FetchRecords(query HistoryQuery) ([]HistoryRecord, error) {
+	stmt, args := historySQL(query, func(n int) string {
+		return "?"
+	})
+	rows, err := sqlite.db.Query(stmt, args...)
+	if err != nil {
+		return []HistoryRecord{}, fmt.Errorf("Error while fetching history records: %s", err)
+	}
+	return scanRecords(rows)
+}
+
+// PruneRecords deletes the records of the executions which started before the given time.
+func (sqlite Sqlite3Storage) PruneRecords(before string) error {
+	_, err := sqlite.db.Exec(`DELETE FROM task_history WHERE started_at<?`, before)
+	if err != nil {
+		return fmt.Errorf("Error while pruning history records: %s", err)
+	}
+	return nil
+}
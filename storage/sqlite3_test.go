@@ -0,0 +1,190 @@
+// +build cgo
+
+package storage
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newSqlite3TestStorage(t *testing.T) Sqlite3Storage {
+	t.Helper()
+	store := NewSqlite3Storage(Sqlite3Config{DbName: filepath.Join(t.TempDir(), "tasks.db")})
+	if err := store.Connect(); err != nil {
+		t.Fatal("Could not connect to the database: ", err)
+	}
+	return store
+}
+
+func TestSqlite3AddFailure(t *testing.T) {
+	store := newSqlite3TestStorage(t)
+	if err := store.Initialize(); err != nil {
+		t.Fatal("Could not initialize the database: ", err)
+	}
+	_ = store.Close()
+
+	if err := store.Add(sampleTask); err == nil {
+		t.Error("Adding a task should fail once the database is closed")
+	}
+}
+
+func TestSqlite3SchemaUpgrade(t *testing.T) {
+	store := newSqlite3TestStorage(t)
+	defer store.Close()
+
+	// Tables created by the first versions, along with a task stored by them
+	_, err := store.db.Exec(`
+    CREATE TABLE task_store (
+        id integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+        name text,
+        params text,
+        duration integer,
+        last_run text,
+        next_run text,
+        is_recurring integer,
+        hash text
+    );
+    INSERT INTO task_store(name, params, duration, last_run, next_run, is_recurring, hash)
+    VALUES('legacy', '', '5s', '2018-10-27T09:00:00Z', '2018-10-27T09:00:05Z', 1, 'legacy-hash');
+    CREATE TABLE task_history (
+        id integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+        task_hash text,
+        name text,
+        scheduled_at text,
+        started_at text,
+        finished_at text,
+        duration text,
+        outcome text,
+        error text,
+        attempt text,
+        node_id text
+    );`)
+	if err != nil {
+		t.Fatal("Could not create the baseline schema: ", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := store.Initialize(); err != nil {
+			t.Fatalf("Initializing the database should be idempotent, run %d failed: %v", i+1, err)
+		}
+	}
+
+	tasks, err := store.Fetch()
+	if err != nil || len(tasks) != 1 {
+		t.Fatalf("The legacy task should be fetched, got %+v and error %v", tasks, err)
+	}
+	legacy := TaskAttributes{
+		Hash:        "legacy-hash",
+		Name:        "legacy",
+		LastRun:     "2018-10-27T09:00:00Z",
+		NextRun:     "2018-10-27T09:00:05Z",
+		Duration:    "5s",
+		IsRecurring: "1",
+		Attempt:     "0",
+		Paused:      "0",
+		Modified:    "0",
+	}
+	if !reflect.DeepEqual(tasks[0], legacy) {
+		t.Errorf("The legacy task should be fetched with empty new columns, got %+v", tasks[0])
+	}
+
+	record := HistoryRecord{TaskHash: "legacy-hash", StartedAt: "2018-10-27T09:00:00Z", Attempt: "1", Manual: "1"}
+	if err := store.AddRecord(record); err != nil {
+		t.Fatal("The upgraded history table should accept records: ", err)
+	}
+	if records, _ := store.FetchRecords(HistoryQuery{}); !reflect.DeepEqual(records, []HistoryRecord{record}) {
+		t.Errorf("The record should be fetched along with the new columns, got %+v", records)
+	}
+}
+
+func TestSqlite3Update(t *testing.T) {
+	store := newSqlite3TestStorage(t)
+	defer store.Close()
+	if err := store.Initialize(); err != nil {
+		t.Fatal("Could not initialize the database: ", err)
+	}
+
+	task := TaskAttributes{
+		Hash:        "hash",
+		ID:          "report",
+		Name:        "name",
+		LastRun:     "2018-10-27T09:00:00Z",
+		NextRun:     "2018-10-27T10:00:00Z",
+		Duration:    "1h0m0s",
+		IsRecurring: "1",
+		Cron:        "0 * * * *",
+		Location:    "Europe/Berlin",
+		Retry:       `{"MaxAttempts":3}`,
+		Attempt:     "2",
+		Paused:      "1",
+		Modified:    "1",
+		Options:     `{"Timeout":60000000000}`,
+		Params:      `["a"]`,
+	}
+	// The task isn't stored yet, it's inserted
+	if err := store.Update(task); err != nil {
+		t.Fatal("Updating a task which isn't stored should insert it: ", err)
+	}
+	tasks, _ := store.Fetch()
+	if len(tasks) != 1 || !reflect.DeepEqual(tasks[0], task) {
+		t.Errorf("Every attribute should be stored, got %+v", tasks)
+	}
+
+	task.NextRun = "2018-10-27T11:00:00Z"
+	task.Attempt = "0"
+	task.Paused = "0"
+	if err := store.Update(task); err != nil {
+		t.Fatal("Updating the task should succeed: ", err)
+	}
+	tasks, _ = store.Fetch()
+	if len(tasks) != 1 || !reflect.DeepEqual(tasks[0], task) {
+		t.Errorf("The stored task should be replaced, got %+v", tasks)
+	}
+
+	if err := store.Add(task); err != nil {
+		t.Fatal("Adding a stored task should succeed: ", err)
+	}
+	if tasks, _ = store.Fetch(); len(tasks) != 1 {
+		t.Errorf("Adding a stored task shouldn't store it twice, got %d tasks", len(tasks))
+	}
+}
+
+func TestSqlite3History(t *testing.T) {
+	store := newSqlite3TestStorage(t)
+	defer store.Close()
+	if err := store.Initialize(); err != nil {
+		t.Fatal("Could not initialize the database: ", err)
+	}
+
+	records := []HistoryRecord{
+		{TaskHash: "a", StartedAt: "2018-10-27T09:00:00Z", Outcome: "succeeded", Attempt: "1", Manual: "0"},
+		{TaskHash: "b", StartedAt: "2018-10-27T10:00:00Z", Outcome: "failed", Error: "Failed", Attempt: "1", Manual: "1"},
+		{TaskHash: "a", StartedAt: "2018-10-27T11:00:00Z", Outcome: "succeeded", Attempt: "2", Manual: "0"},
+	}
+	for _, record := range records {
+		if err := store.AddRecord(record); err != nil {
+			t.Fatal("Could not add a record: ", err)
+		}
+	}
+
+	fetched, err := store.FetchRecords(HistoryQuery{})
+	if err != nil || !reflect.DeepEqual(fetched, []HistoryRecord{records[2], records[1], records[0]}) {
+		t.Errorf("Every record should be fetched most recent first, got %+v and error %v", fetched, err)
+	}
+	fetched, _ = store.FetchRecords(HistoryQuery{TaskHash: "a", Limit: 1})
+	if !reflect.DeepEqual(fetched, []HistoryRecord{records[2]}) {
+		t.Errorf("The records should be filtered by task and limited, got %+v", fetched)
+	}
+	fetched, _ = store.FetchRecords(HistoryQuery{Since: "2018-10-27T10:00:00Z", Outcome: "failed"})
+	if !reflect.DeepEqual(fetched, []HistoryRecord{records[1]}) {
+		t.Errorf("The records should be filtered by time and outcome, got %+v", fetched)
+	}
+
+	if err := store.PruneRecords("2018-10-27T10:00:00Z"); err != nil {
+		t.Fatal("Could not prune the records: ", err)
+	}
+	fetched, _ = store.FetchRecords(HistoryQuery{})
+	if !reflect.DeepEqual(fetched, []HistoryRecord{records[2], records[1]}) {
+		t.Errorf("The records older than the given time should be pruned, got %+v", fetched)
+	}
+}
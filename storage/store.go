@@ -5,11 +5,19 @@ package storage
 // worrying about details of converting data to the proper formats.
 type TaskAttributes struct {
 	Hash        string
+	ID          string
 	Name        string
 	LastRun     string
 	NextRun     string
 	Duration    string
 	IsRecurring string
+	Cron        string
+	Location    string
+	Retry       string
+	Attempt     string
+	Paused      string
+	Modified    string
+	Options     string
 	Params      string
 }
 
@@ -17,6 +25,8 @@ type TaskAttributes struct {
 type TaskStore interface {
 	Add(TaskAttributes) error
 	Fetch() ([]TaskAttributes, error)
+	// Update replaces the stored task which has the same hash, the task is added when it's not stored.
+	Update(TaskAttributes) error
 	Remove(TaskAttributes) error
 	Close() error
 }
@@ -2,6 +2,8 @@ package scheduler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"reflect"
 	"strconv"
 	"strings"
@@ -11,12 +13,24 @@ import (
 	"github.com/rakanalh/scheduler/task"
 )
 
+// errStoreClosed is returned when the store is used after the scheduler closed it.
+var errStoreClosed = errors.New("Task store is closed")
+
 type storeBridge struct {
 	store        storage.TaskStore
 	funcRegistry *task.FuncRegistry
+	closed       bool
+}
+
+// name identifies the store in the logs.
+func (sb *storeBridge) name() string {
+	return fmt.Sprintf("%T", sb.store)
 }
 
 func (sb *storeBridge) Add(task *task.Task) error {
+	if sb.closed {
+		return errStoreClosed
+	}
 	attributes, err := sb.getTaskAttributes(task)
 	if err != nil {
 		return err
@@ -24,7 +38,22 @@ func (sb *storeBridge) Add(task *task.Task) error {
 	return sb.store.Add(attributes)
 }
 
+// Update replaces the stored attributes of the task, such as its schedule and retry state.
+func (sb *storeBridge) Update(task *task.Task) error {
+	if sb.closed {
+		return errStoreClosed
+	}
+	attributes, err := sb.getTaskAttributes(task)
+	if err != nil {
+		return err
+	}
+	return sb.store.Update(attributes)
+}
+
 func (sb *storeBridge) Fetch() ([]*task.Task, error) {
+	if sb.closed {
+		return []*task.Task{}, errStoreClosed
+	}
 	storedTasks, err := sb.store.Fetch()
 	if err != nil {
 		return []*task.Task{}, err
@@ -51,6 +80,53 @@ func (sb *storeBridge) Fetch() ([]*task.Task, error) {
 			return nil, err
 		}
 
+		var cron *task.Cron
+		if storedTask.Cron != "" {
+			cron, err = task.ParseCron(storedTask.Cron)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// Times are stored with a fixed offset, restore them into the task's
+		// time zone so that recurrences are computed on its wall clock.
+		var location *time.Location
+		zone := time.Local
+		if storedTask.Location != "" {
+			location, err = time.LoadLocation(storedTask.Location)
+			if err != nil {
+				return nil, err
+			}
+			zone = location
+		}
+
+		var retry *task.RetryPolicy
+		if storedTask.Retry != "" {
+			policy, err := task.ParseRetryPolicy(storedTask.Retry)
+			if err != nil {
+				return nil, err
+			}
+			retry = &policy
+		}
+
+		var options taskOptions
+		if storedTask.Options != "" {
+			if err := json.Unmarshal([]byte(storedTask.Options), &options); err != nil {
+				return nil, err
+			}
+		}
+
+		paused := storedTask.Paused == "1"
+		modified := storedTask.Modified == "1"
+
+		attempt := 0
+		if storedTask.Attempt != "" {
+			attempt, err = strconv.Atoi(storedTask.Attempt)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		funcMeta, err := sb.funcRegistry.Get(storedTask.Name)
 		if err != nil {
 			return nil, err
@@ -64,15 +140,46 @@ func (sb *storeBridge) Fetch() ([]*task.Task, error) {
 		t := task.NewWithSchedule(funcMeta, params, task.Schedule{
 			IsRecurring: isRecurring == 1,
 			Duration:    time.Duration(duration),
-			LastRun:     lastRun,
-			NextRun:     nextRun,
+			LastRun:     lastRun.In(zone),
+			NextRun:     nextRun.In(zone),
+			Cron:        cron,
+			Location:    location,
 		})
+		t.ID = task.ID(storedTask.ID)
+		t.Retry = retry
+		options.apply(t)
+		t.Attempt = attempt
+		t.Paused = paused
+		t.Modified = modified
+		if err := sb.migrateHash(storedTask, t); err != nil {
+			return nil, err
+		}
 		tasks = append(tasks, t)
 	}
 	return tasks, nil
 }
 
+// migrateHash stores the task again under its hash when it was stored under another one, such as the
+// hashes of one-off tasks computed by older versions which didn't include the time the task was due.
+// Otherwise removing the task would leave the stored one behind.
+func (sb *storeBridge) migrateHash(storedTask storage.TaskAttributes, t *task.Task) error {
+	if storedTask.Hash == "" || storedTask.Hash == string(t.Hash()) {
+		return nil
+	}
+	if err := sb.store.Remove(storedTask); err != nil {
+		return err
+	}
+	attributes, err := sb.getTaskAttributes(t)
+	if err != nil {
+		return err
+	}
+	return sb.store.Add(attributes)
+}
+
 func (sb *storeBridge) Remove(task *task.Task) error {
+	if sb.closed {
+		return errStoreClosed
+	}
 	attributes, err := sb.getTaskAttributes(task)
 	if err != nil {
 		return err
@@ -91,17 +198,157 @@ func (sb *storeBridge) getTaskAttributes(task *task.Task) (storage.TaskAttribute
 		isRecurring = 1
 	}
 
+	cron := ""
+	if task.Cron != nil {
+		cron = task.Cron.String()
+	}
+
+	location := ""
+	if task.Location != nil {
+		location = task.Location.String()
+	}
+
+	retry := ""
+	if task.Retry != nil {
+		retry = task.Retry.String()
+	}
+
+	options, err := optionsOf(task).encode()
+	if err != nil {
+		return storage.TaskAttributes{}, err
+	}
+
+	paused := 0
+	if task.Paused {
+		paused = 1
+	}
+
+	modified := 0
+	if task.Modified {
+		modified = 1
+	}
+
 	return storage.TaskAttributes{
 		Hash:        string(task.Hash()),
+		ID:          string(task.ID),
 		Name:        task.Func.Name,
 		LastRun:     task.LastRun.Format(time.RFC3339),
 		NextRun:     task.NextRun.Format(time.RFC3339),
 		Duration:    task.Duration.String(),
 		IsRecurring: strconv.Itoa(isRecurring),
+		Cron:        cron,
+		Location:    location,
+		Retry:       retry,
+		Attempt:     strconv.Itoa(task.Attempt),
+		Paused:      strconv.Itoa(paused),
+		Modified:    strconv.Itoa(modified),
+		Options:     options,
 		Params:      params,
 	}, nil
 }
 
+// taskOptions holds the execution options of a task, they are stored as a single encoded attribute.
+type taskOptions struct {
+	Timeout      time.Duration      `json:",omitempty"`
+	Overlap      task.OverlapPolicy `json:",omitempty"`
+	Misfire      task.MisfirePolicy `json:",omitempty"`
+	MisfireGrace time.Duration      `json:",omitempty"`
+	CatchUpLimit int                `json:",omitempty"`
+}
+
+func optionsOf(t *task.Task) taskOptions {
+	return taskOptions{
+		Timeout:      t.Timeout,
+		Overlap:      t.Overlap,
+		Misfire:      t.Misfire,
+		MisfireGrace: t.MisfireGrace,
+		CatchUpLimit: t.CatchUpLimit,
+	}
+}
+
+func (options taskOptions) apply(t *task.Task) {
+	t.Timeout = options.Timeout
+	t.Overlap = options.Overlap
+	t.Misfire = options.Misfire
+	t.MisfireGrace = options.MisfireGrace
+	t.CatchUpLimit = options.CatchUpLimit
+}
+
+// encode encodes the options, tasks which use the defaults are stored without options.
+func (options taskOptions) encode() (string, error) {
+	if options == (taskOptions{}) {
+		return "", nil
+	}
+	data, err := json.Marshal(options)
+	return string(data), err
+}
+
+// storeWrite is a change of the stored tasks which is applied once scheduler.mu is released.
+type storeWrite struct {
+	apply func(sb *storeBridge) error
+	// result receives the error of writes which are waited for, the errors of the other writes
+	// are reported to the error handler.
+	result *error
+}
+
+// queueStore queues a write of a copy of the task as it is now. The writes are applied in the order they
+// were queued once scheduler.mu is released, so that the dispatcher doesn't wait for the store while
+// holding it. It must be called with scheduler.mu held.
+func (scheduler *Scheduler) queueStore(write func(sb *storeBridge, t *task.Task) error, t *task.Task) {
+	t = t.Clone()
+	scheduler.queueWrite(storeWrite{apply: func(sb *storeBridge) error {
+		return write(sb, t)
+	}})
+}
+
+// writeStore writes the task after the writes queued before it and returns its error, the errors of
+// the queued writes are reported by unlock. It must be called with scheduler.mu held.
+func (scheduler *Scheduler) writeStore(write func(sb *storeBridge, t *task.Task) error, t *task.Task) error {
+	return scheduler.waitStore(func(sb *storeBridge) error {
+		return write(sb, t)
+	})
+}
+
+// waitStore applies the change of the store after the writes queued before it and returns its error.
+// It must be called with scheduler.mu held.
+func (scheduler *Scheduler) waitStore(apply func(sb *storeBridge) error) error {
+	var err error
+	scheduler.queueWrite(storeWrite{apply: apply, result: &err})
+	for _, writeErr := range scheduler.flushStore() {
+		scheduler.queueError(writeErr)
+	}
+	return err
+}
+
+func (scheduler *Scheduler) queueWrite(write storeWrite) {
+	scheduler.writesMu.Lock()
+	scheduler.writes = append(scheduler.writes, write)
+	scheduler.writesMu.Unlock()
+}
+
+// flushStore applies the queued writes and returns their errors. Once it returns, the writes which were
+// queued before it was called are applied, possibly by another goroutine flushing the store concurrently.
+func (scheduler *Scheduler) flushStore() []error {
+	scheduler.storeMu.Lock()
+	defer scheduler.storeMu.Unlock()
+
+	scheduler.writesMu.Lock()
+	writes := scheduler.writes
+	scheduler.writes = nil
+	scheduler.writesMu.Unlock()
+
+	var errs []error
+	for _, write := range writes {
+		err := write.apply(&scheduler.taskStore)
+		if write.result != nil {
+			*write.result = err
+		} else if err != nil && err != errStoreClosed {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
 func paramsToString(params []task.Param) (string, error) {
 	var paramsList []string
 	for _, param := range params {
@@ -0,0 +1,79 @@
+// +build cgo
+
+package scheduler
+
+import (
+	"context"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rakanalh/scheduler/storage"
+	"github.com/rakanalh/scheduler/task"
+)
+
+var sqliteRuns int32
+
+func countSqliteRun() {
+	atomic.AddInt32(&sqliteRuns, 1)
+}
+
+func openSqliteStore(t *testing.T, dbName string) storage.Sqlite3Storage {
+	t.Helper()
+	store := storage.NewSqlite3Storage(storage.Sqlite3Config{DbName: dbName})
+	if err := store.Connect(); err != nil {
+		t.Fatal("Could not connect to the database: ", err)
+	}
+	if err := store.Initialize(); err != nil {
+		t.Fatal("Could not initialize the database: ", err)
+	}
+	return store
+}
+
+func TestRestartMigratesLegacyHashSqlite(t *testing.T) {
+	atomic.StoreInt32(&sqliteRuns, 0)
+	dbName := filepath.Join(t.TempDir(), "tasks.db")
+	store := openSqliteStore(t, dbName)
+	funcRegistry := task.NewFuncRegistry()
+	oneOffTask := newTask(funcRegistry, countSqliteRun)
+	oneOffTask.NextRun = time.Now().Add(-time.Millisecond)
+	bridge := getStoreBridge(funcRegistry, store)
+	attributes, _ := bridge.getTaskAttributes(oneOffTask)
+	attributes.Hash = "legacy-hash"
+	_ = store.Add(attributes)
+	_ = store.Close()
+
+	// The process restarts twice, the task must run once and not be left in the store
+	for restart := 0; restart < 2; restart++ {
+		store = openSqliteStore(t, dbName)
+		scheduler := New(store)
+		_, _ = scheduler.funcRegistry.Add(countSqliteRun)
+		results := make(chan task.Result, 1)
+		scheduler.OnResult(func(result task.Result) {
+			results <- result
+		})
+		if err := scheduler.Start(); err != nil {
+			t.Fatal("Could not start the scheduler: ", err)
+		}
+		if restart == 0 {
+			select {
+			case <-results:
+			case <-time.After(time.Second):
+				t.Fatal("The stored task should run after the restart")
+			}
+		}
+		if err := scheduler.Shutdown(context.Background()); err != nil {
+			t.Fatal("Could not shut the scheduler down: ", err)
+		}
+	}
+
+	if runs := atomic.LoadInt32(&sqliteRuns); runs != 1 {
+		t.Errorf("The stored task should run once, got %d runs", runs)
+	}
+	store = openSqliteStore(t, dbName)
+	defer store.Close()
+	if stored, _ := store.Fetch(); len(stored) != 0 {
+		t.Errorf("The executed task should be removed from the store, got %+v", stored)
+	}
+}
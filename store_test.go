@@ -2,6 +2,7 @@ package scheduler
 
 import (
 	"testing"
+	"time"
 
 	"github.com/rakanalh/scheduler/storage"
 	"github.com/rakanalh/scheduler/task"
@@ -102,6 +103,145 @@ func TestFetchWithParams(t *testing.T) {
 	}
 }
 
+func TestFetchWithContextParam(t *testing.T) {
+	mock := task.CallbackMock{}
+	funcRegistry := task.NewFuncRegistry()
+	store := getStoreBridge(funcRegistry, nil)
+	contextTask := newTask(funcRegistry, mock.CallWithContext, "Test")
+	err := store.Add(contextTask)
+	if err != nil {
+		t.Error("Failed to store task")
+	}
+	tasks, err := store.Fetch()
+	if err != nil || len(tasks) != 1 {
+		t.Fatal("Could not read tasks from store")
+	}
+
+	if len(tasks[0].Params) != 1 || tasks[0].Params[0] != "Test" {
+		t.Error("Params should be restored without the context parameter")
+	}
+}
+
+func TestFetchWithID(t *testing.T) {
+	mock := task.CallbackMock{}
+	funcRegistry := task.NewFuncRegistry()
+	store := getStoreBridge(funcRegistry, nil)
+	namedTask := newTask(funcRegistry, mock.CallNoArgs)
+	namedTask.ID = "invoice-reminder-42"
+	_ = store.Add(namedTask)
+
+	tasks, err := store.Fetch()
+	if err != nil || len(tasks) != 1 {
+		t.Fatal("Could not read tasks from store")
+	}
+	if tasks[0].Hash() != "invoice-reminder-42" {
+		t.Error("Restored task should keep its ID")
+	}
+}
+
+func TestFetchMigratesLegacyHash(t *testing.T) {
+	mock := task.CallbackMock{}
+	funcRegistry := task.NewFuncRegistry()
+	memStore := storage.NewMemoryStorage()
+	store := getStoreBridge(funcRegistry, memStore)
+	oneOffTask := newTask(funcRegistry, mock.CallNoArgs)
+	oneOffTask.NextRun = time.Date(2018, time.October, 27, 9, 0, 0, 0, time.UTC)
+	attributes, _ := store.getTaskAttributes(oneOffTask)
+	attributes.Hash = "legacy"
+	_ = memStore.Add(attributes)
+
+	tasks, err := store.Fetch()
+	if err != nil || len(tasks) != 1 {
+		t.Fatal("Could not read tasks from store")
+	}
+	stored, _ := memStore.Fetch()
+	if len(stored) != 1 || stored[0].Hash != string(oneOffTask.Hash()) {
+		t.Error("The task should be stored again under its current hash")
+	}
+}
+
+func TestFetchCron(t *testing.T) {
+	mock := task.CallbackMock{}
+	funcRegistry := task.NewFuncRegistry()
+	store := getStoreBridge(funcRegistry, nil)
+	cronTask := newTask(funcRegistry, mock.CallNoArgs)
+	cronTask.IsRecurring = true
+	cronTask.Cron, _ = task.ParseCron("0 */15 * * 1-5")
+	err := store.Add(cronTask)
+	if err != nil {
+		t.Error("Failed to store task")
+	}
+	tasks, err := store.Fetch()
+	if err != nil || len(tasks) != 1 {
+		t.Fatal("Could not read tasks from store")
+	}
+
+	if tasks[0].Cron == nil || tasks[0].Cron.String() != "0 */15 * * 1-5" {
+		t.Error("Cron expression was not restored from store")
+	}
+	if tasks[0].Hash() != cronTask.Hash() {
+		t.Error("Restored cron task should keep the same hash")
+	}
+}
+
+func TestFetchLocation(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("Time zone database is not available")
+	}
+	mock := task.CallbackMock{}
+	funcRegistry := task.NewFuncRegistry()
+	store := getStoreBridge(funcRegistry, nil)
+	zonedTask := newTask(funcRegistry, mock.CallNoArgs)
+	zonedTask.IsRecurring = true
+	zonedTask.Duration = 24 * time.Hour
+	zonedTask.Location = berlin
+	zonedTask.NextRun = time.Date(2018, time.October, 27, 9, 0, 0, 0, berlin)
+	_ = store.Add(zonedTask)
+
+	tasks, err := store.Fetch()
+	if err != nil || len(tasks) != 1 {
+		t.Fatal("Could not read tasks from store")
+	}
+
+	if tasks[0].Location == nil || tasks[0].Location.String() != "Europe/Berlin" {
+		t.Error("Location was not restored from store")
+	}
+	if tasks[0].NextRun.Location() != tasks[0].Location {
+		t.Error("Restored run times should be in the task's location")
+	}
+	if tasks[0].Hash() != zonedTask.Hash() {
+		t.Error("Restored task should keep the same hash")
+	}
+}
+
+func TestFetchOptions(t *testing.T) {
+	mock := task.CallbackMock{}
+	funcRegistry := task.NewFuncRegistry()
+	store := getStoreBridge(funcRegistry, nil)
+	configuredTask := newTask(funcRegistry, mock.CallNoArgs)
+	configuredTask.Timeout = time.Minute
+	configuredTask.Overlap = task.OverlapQueueOne
+	configuredTask.Misfire = task.MisfireFireAllMissed
+	configuredTask.MisfireGrace = 5 * time.Second
+	configuredTask.CatchUpLimit = 3
+	_ = store.Add(configuredTask)
+
+	tasks, err := store.Fetch()
+	if err != nil || len(tasks) != 1 {
+		t.Fatal("Could not read tasks from store")
+	}
+
+	restored := tasks[0]
+	if restored.Timeout != time.Minute || restored.Overlap != task.OverlapQueueOne {
+		t.Errorf("Timeout and overlap policy were not restored from store, got %s and %d", restored.Timeout, restored.Overlap)
+	}
+	if restored.Misfire != task.MisfireFireAllMissed || restored.MisfireGrace != 5*time.Second || restored.CatchUpLimit != 3 {
+		t.Errorf("Misfire policy was not restored from store, got %d, %s and %d",
+			restored.Misfire, restored.MisfireGrace, restored.CatchUpLimit)
+	}
+}
+
 func TestFetchWrongRunTimes(t *testing.T) {
 	funcRegistry := task.NewFuncRegistry()
 
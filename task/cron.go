@@ -0,0 +1,238 @@
+package task
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron is a parsed cron expression which is used to compute the run times of a recurring task.
+// Both the standard 5-field format (minute hour day-of-month month day-of-week) and the 6-field
+// format with a leading seconds field are supported, as well as the @yearly, @annually, @monthly,
+// @weekly, @daily, @midnight and @hourly macros.
+type Cron struct {
+	expression string
+	second     uint64
+	minute     uint64
+	hour       uint64
+	dom        uint64
+	month      uint64
+	dow        uint64
+	domAny     bool
+	dowAny     bool
+}
+
+type cronBounds struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	secondBounds = cronBounds{0, 59, nil}
+	minuteBounds = cronBounds{0, 59, nil}
+	hourBounds   = cronBounds{0, 23, nil}
+	domBounds    = cronBounds{1, 31, nil}
+	monthBounds  = cronBounds{1, 12, map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowBounds = cronBounds{0, 7, map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron parses the provided cron expression.
+func ParseCron(expression string) (*Cron, error) {
+	spec := strings.TrimSpace(expression)
+	if strings.HasPrefix(spec, "@") {
+		macro, ok := cronMacros[strings.ToLower(spec)]
+		if !ok {
+			return nil, fmt.Errorf("Unknown cron macro %s", spec)
+		}
+		spec = macro
+	}
+
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("Cron expression %q should have 5 or 6 fields, found %d", expression, len(fields))
+	}
+
+	cron := &Cron{expression: strings.TrimSpace(expression)}
+	var err error
+	if cron.second, _, err = parseCronField(fields[0], secondBounds); err != nil {
+		return nil, err
+	}
+	if cron.minute, _, err = parseCronField(fields[1], minuteBounds); err != nil {
+		return nil, err
+	}
+	if cron.hour, _, err = parseCronField(fields[2], hourBounds); err != nil {
+		return nil, err
+	}
+	if cron.dom, cron.domAny, err = parseCronField(fields[3], domBounds); err != nil {
+		return nil, err
+	}
+	if cron.month, _, err = parseCronField(fields[4], monthBounds); err != nil {
+		return nil, err
+	}
+	if cron.dow, cron.dowAny, err = parseCronField(fields[5], dowBounds); err != nil {
+		return nil, err
+	}
+	// Sunday can be written as either 0 or 7
+	if cron.dow&(1<<7) != 0 {
+		cron.dow |= 1
+	}
+	return cron, nil
+}
+
+// String returns the expression the cron was parsed from.
+func (cron *Cron) String() string {
+	return cron.expression
+}
+
+// Next returns the first time matching the cron expression which is strictly after the provided time.
+// The expression is evaluated against the wall clock in the location of the provided time, see
+// resolveWallClock for how daylight saving time transitions are handled.
+// The zero time is returned if the expression can never be satisfied (e.g. 30th of February).
+func (cron *Cron) Next(after time.Time) time.Time {
+	loc := after.Location()
+	wall := wallClock(after)
+	for {
+		wall = cron.nextWallClock(wall)
+		if wall.IsZero() {
+			return time.Time{}
+		}
+		// Wall clock times skipped or repeated by a transition may resolve to an
+		// instant which isn't after the provided time; move on to the next match then.
+		if next := resolveWallClock(wall, loc); next.After(after) {
+			return next
+		}
+	}
+}
+
+func (cron *Cron) nextWallClock(after time.Time) time.Time {
+	t := after.Truncate(time.Second).Add(time.Second)
+	yearLimit := t.Year() + 5
+
+	for t.Year() <= yearLimit {
+		if !hasBit(cron.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !cron.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !hasBit(cron.hour, t.Hour()) {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if !hasBit(cron.minute, t.Minute()) {
+			t = t.Truncate(time.Minute).Add(time.Minute)
+			continue
+		}
+		if !hasBit(cron.second, t.Second()) {
+			t = t.Add(time.Second)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// matchesDay follows the classic cron behavior: when both day-of-month and day-of-week
+// are restricted, a day matching either of them is accepted.
+func (cron *Cron) matchesDay(t time.Time) bool {
+	domMatch := hasBit(cron.dom, t.Day())
+	dowMatch := hasBit(cron.dow, int(t.Weekday()))
+	if cron.domAny || cron.dowAny {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+func parseCronField(field string, bounds cronBounds) (uint64, bool, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		partBits, err := parseCronRange(part, bounds)
+		if err != nil {
+			return 0, false, err
+		}
+		bits |= partBits
+	}
+	return bits, field == "*" || field == "?", nil
+}
+
+func parseCronRange(part string, bounds cronBounds) (uint64, error) {
+	rangeAndStep := strings.SplitN(part, "/", 2)
+	lowAndHigh := strings.SplitN(rangeAndStep[0], "-", 2)
+
+	var start, end int
+	var err error
+	if lowAndHigh[0] == "*" || lowAndHigh[0] == "?" {
+		if len(lowAndHigh) > 1 {
+			return 0, fmt.Errorf("Invalid cron range %q", part)
+		}
+		start, end = bounds.min, bounds.max
+	} else {
+		if start, err = parseCronValue(lowAndHigh[0], bounds); err != nil {
+			return 0, err
+		}
+		end = start
+		if len(lowAndHigh) > 1 {
+			if end, err = parseCronValue(lowAndHigh[1], bounds); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	step := 1
+	if len(rangeAndStep) > 1 {
+		if step, err = strconv.Atoi(rangeAndStep[1]); err != nil || step <= 0 {
+			return 0, fmt.Errorf("Invalid cron step in %q", part)
+		}
+		// A single value with a step (e.g. 5/15) runs until the end of the range
+		if len(lowAndHigh) == 1 {
+			end = bounds.max
+		}
+	}
+
+	if start < bounds.min || end > bounds.max || start > end {
+		return 0, fmt.Errorf("Cron range %q is out of bounds [%d-%d]", part, bounds.min, bounds.max)
+	}
+
+	var bits uint64
+	for value := start; value <= end; value += step {
+		bits |= 1 << uint(value)
+	}
+	return bits, nil
+}
+
+func parseCronValue(value string, bounds cronBounds) (int, error) {
+	if number, ok := bounds.names[strings.ToLower(value)]; ok {
+		return number, nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid cron value %q", value)
+	}
+	return number, nil
+}
+
+func hasBit(bits uint64, value int) bool {
+	return bits&(1<<uint(value)) != 0
+}
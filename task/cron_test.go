@@ -0,0 +1,173 @@
+package task
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	valid := []string{
+		"* * * * *",
+		"0 */15 * * 1-5",
+		"30 0 */15 * * 1-5",
+		"0 9 1,15 jan-jun MON",
+		"5/10 * * * *",
+		"0 0 ? * 7",
+		"@hourly",
+		"@daily",
+		"@Weekly",
+	}
+	for _, expression := range valid {
+		if _, err := ParseCron(expression); err != nil {
+			t.Errorf("%q should be a valid cron expression: %s", expression, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"* * * *",
+		"* * * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*-5 * * * *",
+		"a * * * *",
+		"@every",
+	}
+	for _, expression := range invalid {
+		if _, err := ParseCron(expression); err == nil {
+			t.Errorf("%q should not be a valid cron expression", expression)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	start := time.Date(2018, time.March, 9, 17, 7, 30, 0, time.UTC) // Friday
+	cases := []struct {
+		expression string
+		expected   time.Time
+	}{
+		{"* * * * *", time.Date(2018, time.March, 9, 17, 8, 0, 0, time.UTC)},
+		{"*/15 * * * 1-5", time.Date(2018, time.March, 9, 17, 15, 0, 0, time.UTC)},
+		{"0 */15 * * 1-5", time.Date(2018, time.March, 12, 0, 0, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2018, time.March, 12, 9, 0, 0, 0, time.UTC)},
+		{"*/20 * * * * *", time.Date(2018, time.March, 9, 17, 7, 40, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2018, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 12 13 * 5", time.Date(2018, time.March, 13, 12, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2018, time.March, 11, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2018, time.March, 9, 18, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2018, time.March, 10, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, c := range cases {
+		cron, err := ParseCron(c.expression)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %s", c.expression, err)
+		}
+		if next := cron.Next(start); !next.Equal(c.expected) {
+			t.Errorf("%q: expected next run at %s, got %s", c.expression, c.expected, next)
+		}
+	}
+
+	cron, _ := ParseCron("0 0 30 2 *")
+	if next := cron.Next(start); !next.IsZero() {
+		t.Error("An expression that never matches should return the zero time")
+	}
+}
+
+func TestTaskRunScheduledCronRun(t *testing.T) {
+	mock := CallbackMock{}
+	mock.On("CallNoArgs").Return()
+
+	cron, _ := ParseCron("*/15 * * * *")
+	nextRun := time.Date(2018, time.March, 9, 17, 15, 0, 0, time.UTC)
+	task := newTestTaskWithSchedule(t, mock.CallNoArgs, []Param{}, Schedule{
+		IsRecurring: true,
+		NextRun:     nextRun,
+		Cron:        cron,
+	})
+	task.Run()
+
+	if !task.LastRun.Equal(nextRun) {
+		t.Error("LastRun should be set to the previous NextRun")
+	}
+	if !task.NextRun.Equal(nextRun.Add(15 * time.Minute)) {
+		t.Error("NextRun should be computed from the cron expression")
+	}
+
+	mock.AssertExpectations(t)
+}
+
+func TestCronNextAcrossDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("Time zone database is not available")
+	}
+
+	daily, _ := ParseCron("0 9 * * *")
+	next := daily.Next(time.Date(2018, time.March, 24, 9, 0, 0, 0, berlin))
+	if !next.Equal(time.Date(2018, time.March, 25, 9, 0, 0, 0, berlin)) || next.Hour() != 9 {
+		t.Error("Daily cron should keep its wall clock time across DST, got", next)
+	}
+
+	// Clocks jump from 02:00 to 03:00, the skipped runs fire once at the transition.
+	skipped, _ := ParseCron("*/15 2-3 * * *")
+	next = skipped.Next(time.Date(2018, time.March, 25, 1, 59, 0, 0, berlin))
+	if !next.Equal(time.Date(2018, time.March, 25, 3, 0, 0, 0, berlin)) {
+		t.Error("Skipped wall clock time should run at the transition, got", next)
+	}
+	next = skipped.Next(next)
+	if !next.Equal(time.Date(2018, time.March, 25, 3, 15, 0, 0, berlin)) {
+		t.Error("Runs after the transition should follow the wall clock, got", next)
+	}
+
+	// Clocks fall back from 03:00 to 02:00, the repeated hour only runs once.
+	repeated, _ := ParseCron("30 2 * * *")
+	first := repeated.Next(time.Date(2018, time.October, 28, 1, 0, 0, 0, berlin))
+	if _, offset := first.Zone(); first.Hour() != 2 || offset != 2*60*60 {
+		t.Error("Repeated wall clock time should run at its first occurrence, got", first)
+	}
+	next = repeated.Next(first)
+	if !next.Equal(time.Date(2018, time.October, 29, 2, 30, 0, 0, berlin)) {
+		t.Error("Repeated wall clock time should only run once, got", next)
+	}
+}
+
+func TestCronNextAcrossDSTWestOfUTC(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("Time zone database is not available")
+	}
+	saoPaulo, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		t.Skip("Time zone database is not available")
+	}
+
+	// Clocks jump from 02:00 to 03:00, the skipped run fires once at the transition.
+	daily, _ := ParseCron("30 2 * * *")
+	next := daily.Next(time.Date(2024, time.March, 9, 2, 30, 0, 0, newYork))
+	if !next.Equal(time.Date(2024, time.March, 10, 3, 0, 0, 0, newYork)) {
+		t.Error("Skipped wall clock time should run at the transition, got", next)
+	}
+	next = daily.Next(next)
+	if !next.Equal(time.Date(2024, time.March, 11, 2, 30, 0, 0, newYork)) {
+		t.Error("Runs after the transition should follow the wall clock, got", next)
+	}
+
+	// Clocks jump from 00:00 to 01:00
+	midnight, _ := ParseCron("30 0 * * *")
+	next = midnight.Next(time.Date(2018, time.November, 3, 0, 30, 0, 0, saoPaulo))
+	if !next.Equal(time.Date(2018, time.November, 4, 1, 0, 0, 0, saoPaulo)) {
+		t.Error("Skipped wall clock time should run at the transition, got", next)
+	}
+	next = midnight.Next(next)
+	if !next.Equal(time.Date(2018, time.November, 5, 0, 30, 0, 0, saoPaulo)) {
+		t.Error("Runs after the transition should follow the wall clock, got", next)
+	}
+}
@@ -0,0 +1,19 @@
+package task
+
+// MisfirePolicy defines what happens to a run which is dispatched later than its misfire
+// grace, because the scheduler was behind or not running when the run was due.
+type MisfirePolicy int
+
+const (
+	// MisfireDefault applies the scheduler's misfire policy.
+	MisfireDefault MisfirePolicy = iota
+	// MisfireFireOnceNow executes the late run once, the runs missed in between are skipped.
+	MisfireFireOnceNow
+	// MisfireFireAllMissed executes every run which was missed, one after the other and in order.
+	MisfireFireAllMissed
+	// MisfireSkipToNext skips the late run and waits for the next regular run of recurring tasks.
+	// Late one-off tasks have no next run, they are removed without being executed.
+	MisfireSkipToNext
+	// MisfireDrop removes the task without executing the late run.
+	MisfireDrop
+)
@@ -1,6 +1,10 @@
 package task
 
-import "github.com/stretchr/testify/mock"
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
 
 // CallbackMock is used for testing Task
 type CallbackMock struct {
@@ -21,3 +25,26 @@ func (m *CallbackMock) CallWithArgs(arg1 string, arg2 bool) {
 func (m *CallbackMock) CallWithChan(channel chan bool) {
 	m.Called(channel)
 }
+
+// CallWithError is a dummy function which returns an error
+func (m *CallbackMock) CallWithError(arg string) error {
+	args := m.Called(arg)
+	return args.Error(0)
+}
+
+// CallWithResult is a dummy function which returns a result value and an error
+func (m *CallbackMock) CallWithResult() (int, error) {
+	args := m.Called()
+	return args.Int(0), args.Error(1)
+}
+
+// CallWithPanic is a dummy function which panics
+func (m *CallbackMock) CallWithPanic() {
+	m.Called()
+	panic("CallWithPanic")
+}
+
+// CallWithContext is a dummy function which accepts a context along with an argument
+func (m *CallbackMock) CallWithContext(ctx context.Context, arg string) {
+	m.Called(ctx, arg)
+}
@@ -0,0 +1,17 @@
+package task
+
+// OverlapPolicy defines what happens when a task is due while a previous run of it is still executing.
+type OverlapPolicy int
+
+const (
+	// OverlapAllow executes the run alongside the runs which are still executing.
+	OverlapAllow OverlapPolicy = iota
+	// OverlapSkip skips the run.
+	OverlapSkip
+	// OverlapQueueOne executes the run once the running one finished. At most one run
+	// is kept waiting, further overlapping runs are skipped.
+	OverlapQueueOne
+	// OverlapCancelPrevious cancels the context of the runs which are still executing
+	// and executes the new run right away.
+	OverlapCancelPrevious
+)
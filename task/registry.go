@@ -1,9 +1,11 @@
 package task
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"runtime"
+	"sync"
 )
 
 // Function is a pointer to the callback function
@@ -20,7 +22,9 @@ type FunctionMeta struct {
 }
 
 // FuncRegistry holds the list of all registered task functions.
+// It is safe for concurrent use by multiple goroutines.
 type FuncRegistry struct {
+	mu    sync.RWMutex
 	funcs map[string]FunctionMeta
 }
 
@@ -38,9 +42,13 @@ func (reg *FuncRegistry) Add(function Function) (FunctionMeta, error) {
 		return FunctionMeta{}, fmt.Errorf("Provided function value is not an actual function")
 	}
 
-	name := runtime.FuncForPC(funcValue.Pointer()).Name()
-	funcInstance, err := reg.Get(name)
-	if err == nil {
+	name := FuncName(function)
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	funcInstance, ok := reg.funcs[name]
+	if ok {
 		return funcInstance, nil
 	}
 	reg.funcs[name] = FunctionMeta{
@@ -51,8 +59,21 @@ func (reg *FuncRegistry) Add(function Function) (FunctionMeta, error) {
 	return reg.funcs[name], nil
 }
 
+// FuncName returns the name under which the function is registered, which is the FunctionMeta's Name.
+// It returns an empty string if the value is not a function.
+func FuncName(function Function) string {
+	funcValue := reflect.ValueOf(function)
+	if funcValue.Kind() != reflect.Func {
+		return ""
+	}
+	return runtime.FuncForPC(funcValue.Pointer()).Name()
+}
+
 // Get returns the FunctionMeta instance which holds all information about any single registered task function.
 func (reg *FuncRegistry) Get(name string) (FunctionMeta, error) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
 	function, ok := reg.funcs[name]
 	if ok {
 		return function, nil
@@ -62,6 +83,9 @@ func (reg *FuncRegistry) Get(name string) (FunctionMeta, error) {
 
 // Exists checks if a function with provided name exists.
 func (reg *FuncRegistry) Exists(name string) bool {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
 	_, ok := reg.funcs[name]
 	if ok {
 		return true
@@ -69,21 +93,59 @@ func (reg *FuncRegistry) Exists(name string) bool {
 	return false
 }
 
-// Params returns the list of parameter types
+// Params returns the list of parameter types.
+// A leading context.Context parameter is provided by the scheduler and isn't part of the list.
 func (meta *FunctionMeta) Params() []reflect.Type {
 	funcType := reflect.TypeOf(meta.function)
-	paramTypes := make([]reflect.Type, funcType.NumIn())
-	for idx := 0; idx < funcType.NumIn(); idx++ {
+	first := firstParam(funcType)
+	paramTypes := make([]reflect.Type, funcType.NumIn()-first)
+	for idx := first; idx < funcType.NumIn(); idx++ {
 		in := funcType.In(idx)
-		paramTypes[idx] = in
+		paramTypes[idx-first] = in
 	}
 	return paramTypes
 }
 
+// AcceptsContext reports whether the function's first parameter is a context.Context.
+func (meta *FunctionMeta) AcceptsContext() bool {
+	return firstParam(reflect.TypeOf(meta.function)) == 1
+}
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// firstParam returns the index of the first parameter which is provided by the caller,
+// skipping the context.Context parameter that the scheduler injects.
+func firstParam(funcType reflect.Type) int {
+	if funcType.NumIn() > 0 && funcType.In(0) == contextType {
+		return 1
+	}
+	return 0
+}
+
+// results converts the values returned by the function into the result value and error.
+// When the last return value is an error it's returned as the error, the first remaining return value is the
+// result value and the other ones are ignored.
+func (meta *FunctionMeta) results(values []reflect.Value) (interface{}, error) {
+	var err error
+	if len(values) > 0 && values[len(values)-1].Type() == errorType {
+		last := values[len(values)-1]
+		if !last.IsNil() {
+			err = last.Interface().(error)
+		}
+		values = values[:len(values)-1]
+	}
+	if len(values) == 0 {
+		return nil, err
+	}
+	return values[0].Interface(), err
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
 func (reg *FuncRegistry) resolveParamTypes(function Function) map[string]reflect.Type {
 	paramTypes := make(map[string]reflect.Type)
 	funcType := reflect.TypeOf(function)
-	for idx := 0; idx < funcType.NumIn(); idx++ {
+	for idx := firstParam(funcType); idx < funcType.NumIn(); idx++ {
 		in := funcType.In(idx)
 		paramTypes[in.Name()] = in
 	}
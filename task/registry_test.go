@@ -1,7 +1,9 @@
 package task
 
 import (
+	"errors"
 	"reflect"
+	"sync"
 	"testing"
 )
 
@@ -22,6 +24,35 @@ func TestRegistryFake(t *testing.T) {
 	}
 }
 
+func TestRegistryReturnTypes(t *testing.T) {
+	mock := CallbackMock{}
+	functions := []Function{
+		mock.CallWithError,
+		mock.CallWithResult,
+		func() string { return "" },
+		func() (string, string) { return "", "" },
+		func() (int, string, error) { return 0, "", nil },
+	}
+	for _, function := range functions {
+		if _, err := newFuncMeta(function); err != nil {
+			t.Error("Failed to register function with return values: ", err)
+		}
+	}
+
+	// The first value is the result, the others are ignored unless the last one is an error
+	function := func() (int, string, error) { return 1, "ignored", errors.New("Failed") }
+	meta, _ := newFuncMeta(function)
+	value, err := meta.results(reflect.ValueOf(function).Call(nil))
+	if value != 1 || err == nil || err.Error() != "Failed" {
+		t.Errorf("The first value and the error should be returned, got %v and %v", value, err)
+	}
+	ignored := func() (string, string) { return "result", "ignored" }
+	meta, _ = newFuncMeta(ignored)
+	if value, err := meta.results(reflect.ValueOf(ignored).Call(nil)); value != "result" || err != nil {
+		t.Errorf("The first value should be returned, got %v and %v", value, err)
+	}
+}
+
 func TestGet(t *testing.T) {
 	mock := CallbackMock{}
 
@@ -76,6 +107,24 @@ func TestExists(t *testing.T) {
 	}
 }
 
+func TestConcurrentAdd(t *testing.T) {
+	mock := CallbackMock{}
+	funcRegistry := NewFuncRegistry()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			funcMeta, err := funcRegistry.Add(mock.CallWithArgs)
+			if err != nil || !funcRegistry.Exists(funcMeta.Name) {
+				t.Error("Failed to register function")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
 func TestFunctionMetaParams(t *testing.T) {
 	mock := CallbackMock{}
 	funcMeta, _ := newFuncMeta(mock.CallWithArgs)
@@ -92,6 +141,36 @@ func TestFunctionMetaParams(t *testing.T) {
 	}
 }
 
+func TestFunctionMetaParamsWithContext(t *testing.T) {
+	mock := CallbackMock{}
+	funcMeta, _ := newFuncMeta(mock.CallWithContext)
+	params := funcMeta.Params()
+
+	if !funcMeta.AcceptsContext() {
+		t.Error("Function should be detected as accepting a context")
+	}
+	if len(params) != 1 || params[0] != reflect.TypeOf("") {
+		t.Error("Context parameter should not be part of the params")
+	}
+
+	funcMeta, _ = newFuncMeta(mock.CallWithArgs)
+	if funcMeta.AcceptsContext() {
+		t.Error("Function without a context should not be detected as accepting one")
+	}
+}
+
+func TestFuncName(t *testing.T) {
+	mock := CallbackMock{}
+	funcMeta, _ := newFuncMeta(mock.CallNoArgs)
+
+	if FuncName(mock.CallNoArgs) != funcMeta.Name {
+		t.Error("FuncName should return the name the function is registered with")
+	}
+	if FuncName("not a function") != "" {
+		t.Error("FuncName should return an empty string for values which are not functions")
+	}
+}
+
 func newFuncMeta(function Function) (FunctionMeta, error) {
 	funcRegistry := NewFuncRegistry()
 	return funcRegistry.Add(function)
@@ -0,0 +1,108 @@
+package task
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// BackoffStrategy defines how the delay between retries of a failed task grows.
+type BackoffStrategy int
+
+const (
+	// FixedBackoff waits the same delay before every retry.
+	FixedBackoff BackoffStrategy = iota
+	// ExponentialBackoff doubles the delay after every failed attempt.
+	ExponentialBackoff
+	// JitteredBackoff is an exponential backoff where every delay is randomized between
+	// half and the full exponential delay, which spreads retries of tasks failing together.
+	JitteredBackoff
+)
+
+// RetryPolicy defines how often and when a failed task is retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts of a run, including the first one.
+	MaxAttempts int
+	// Backoff is the strategy used to compute the delay before every retry.
+	Backoff BackoffStrategy
+	// Delay is the delay before the first retry.
+	Delay time.Duration
+	// MaxDelay caps the delay between retries, zero means no cap.
+	MaxDelay time.Duration
+	// MaxElapsed stops retrying once that much time passed since the failed run was due, zero means no limit.
+	MaxElapsed time.Duration
+}
+
+// NextDelay returns the delay before the retry which follows the given number of failed attempts.
+// Exponential delays saturate at the longest duration rather than overflowing.
+func (policy RetryPolicy) NextDelay(failedAttempts int) time.Duration {
+	delay := policy.Delay
+	if policy.Backoff != FixedBackoff {
+		for i := 1; i < failedAttempts && delay > 0; i++ {
+			if delay > math.MaxInt64/2 {
+				delay = math.MaxInt64
+				break
+			}
+			delay *= 2
+			if policy.MaxDelay > 0 && delay >= policy.MaxDelay {
+				break
+			}
+		}
+	}
+	if policy.MaxDelay > 0 && delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+	if policy.Backoff == JitteredBackoff && delay > 1 {
+		delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+	}
+	return delay
+}
+
+// RetryAt returns the time at which a run which failed at failedAt should be retried.
+// The run was due at dueAt and failed for the given number of attempts. It returns false
+// when the policy doesn't allow any more retries.
+func (policy RetryPolicy) RetryAt(dueAt, failedAt time.Time, failedAttempts int) (time.Time, bool) {
+	if failedAttempts >= policy.MaxAttempts {
+		return time.Time{}, false
+	}
+	retryAt := failedAt.Add(policy.NextDelay(failedAttempts))
+	if policy.MaxElapsed > 0 && retryAt.Sub(dueAt) > policy.MaxElapsed {
+		return time.Time{}, false
+	}
+	return retryAt, true
+}
+
+// String encodes the policy so that it can be stored along with the task.
+func (policy RetryPolicy) String() string {
+	data, _ := json.Marshal(policy)
+	return string(data)
+}
+
+// ParseRetryPolicy decodes a policy which was encoded using RetryPolicy.String.
+func ParseRetryPolicy(encoded string) (RetryPolicy, error) {
+	var policy RetryPolicy
+	err := json.Unmarshal([]byte(encoded), &policy)
+	return policy, err
+}
+
+// ScheduleRetry counts the failed attempt and moves the task's next run to the retry time.
+// The run being retried is kept as the task's LastRun, which recurring tasks resume their cadence from.
+func (task *Task) ScheduleRetry(retryAt time.Time) {
+	task.mu.Lock()
+	defer task.mu.Unlock()
+
+	if task.Attempt == 0 && !task.IsRecurring {
+		task.LastRun = task.NextRun
+	}
+	task.Attempt++
+	task.NextRun = retryAt
+}
+
+// ResetRetries clears the failed attempts count once a run succeeded or can't be retried anymore.
+func (task *Task) ResetRetries() {
+	task.mu.Lock()
+	defer task.mu.Unlock()
+
+	task.Attempt = 0
+}
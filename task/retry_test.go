@@ -0,0 +1,106 @@
+package task
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyNextDelay(t *testing.T) {
+	fixed := RetryPolicy{Backoff: FixedBackoff, Delay: time.Second}
+	if fixed.NextDelay(1) != time.Second || fixed.NextDelay(5) != time.Second {
+		t.Error("Fixed backoff should always wait the same delay")
+	}
+
+	exponential := RetryPolicy{Backoff: ExponentialBackoff, Delay: time.Second, MaxDelay: 10 * time.Second}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, delay := range expected {
+		if exponential.NextDelay(i+1) != delay {
+			t.Errorf("Expected exponential delay %s after %d attempts, got %s", delay, i+1, exponential.NextDelay(i+1))
+		}
+	}
+	if exponential.NextDelay(100) != 10*time.Second {
+		t.Error("Exponential backoff should be capped by MaxDelay")
+	}
+
+	jittered := RetryPolicy{Backoff: JitteredBackoff, Delay: time.Second}
+	for i := 0; i < 20; i++ {
+		if delay := jittered.NextDelay(3); delay < 2*time.Second || delay > 4*time.Second {
+			t.Error("Jittered delay should be between half and the full exponential delay, got", delay)
+		}
+	}
+}
+
+func TestRetryPolicyNextDelaySaturates(t *testing.T) {
+	failedAt := time.Now()
+	for _, backoff := range []BackoffStrategy{ExponentialBackoff, JitteredBackoff} {
+		policy := RetryPolicy{MaxAttempts: 1000, Backoff: backoff, Delay: time.Second}
+		for attempts := 30; attempts < policy.MaxAttempts; attempts++ {
+			if delay := policy.NextDelay(attempts); delay < time.Duration(1<<28)*time.Second {
+				t.Fatalf("The delay after %d attempts shouldn't overflow, got %s", attempts, delay)
+			}
+			if retryAt, ok := policy.RetryAt(failedAt, failedAt, attempts); !ok || !retryAt.After(failedAt) {
+				t.Fatalf("The retry after %d attempts should be in the future, got %s", attempts, retryAt)
+			}
+		}
+	}
+}
+
+func TestRetryPolicyRetryAt(t *testing.T) {
+	dueAt := time.Now()
+	policy := RetryPolicy{MaxAttempts: 3, Backoff: FixedBackoff, Delay: time.Minute, MaxElapsed: 3 * time.Minute}
+
+	retryAt, ok := policy.RetryAt(dueAt, dueAt, 1)
+	if !ok || retryAt != dueAt.Add(time.Minute) {
+		t.Error("First failure should be retried after the delay")
+	}
+	if _, ok := policy.RetryAt(dueAt, dueAt, 3); ok {
+		t.Error("No retries should be allowed after MaxAttempts")
+	}
+	if _, ok := policy.RetryAt(dueAt, dueAt.Add(150*time.Second), 2); ok {
+		t.Error("No retries should be allowed past MaxElapsed")
+	}
+}
+
+func TestRetryPolicyString(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, Backoff: JitteredBackoff, Delay: time.Second, MaxDelay: time.Minute, MaxElapsed: time.Hour}
+	parsed, err := ParseRetryPolicy(policy.String())
+	if err != nil || parsed != policy {
+		t.Error("Encoded retry policy should be parsed back")
+	}
+	if _, err := ParseRetryPolicy("SomeCorruptString"); err == nil {
+		t.Error("Parsing a corrupt policy should fail")
+	}
+}
+
+func TestTaskScheduleRetry(t *testing.T) {
+	mock := CallbackMock{}
+	dueAt := time.Now()
+
+	oneOff := newTestTask(t, mock.CallNoArgs, []Param{})
+	oneOff.NextRun = dueAt
+	oneOff.ScheduleRetry(dueAt.Add(time.Second))
+	if oneOff.Attempt != 1 || oneOff.LastRun != dueAt || oneOff.NextRun != dueAt.Add(time.Second) {
+		t.Error("Retrying a one-off task should keep the failed run as LastRun")
+	}
+
+	recurring := newTestTask(t, mock.CallNoArgs, []Param{})
+	recurring.IsRecurring = true
+	recurring.Duration = time.Minute
+	recurring.NextRun = dueAt
+	recurring.ScheduleNextRun()
+	recurring.ScheduleRetry(dueAt.Add(time.Second))
+	if recurring.NextRun != dueAt.Add(time.Second) {
+		t.Error("Retry should be scheduled before the next regular run")
+	}
+
+	// Dispatching the retry resumes the regular cadence
+	recurring.ScheduleNextRun()
+	if recurring.NextRun != dueAt.Add(time.Minute) || recurring.LastRun != dueAt {
+		t.Error("Retrying a recurring task should not move its cadence")
+	}
+
+	recurring.ResetRetries()
+	if recurring.Attempt != 0 {
+		t.Error("Attempts should be reset")
+	}
+}
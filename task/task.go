@@ -1,10 +1,13 @@
 package task
 
 import (
+	"context"
 	"crypto/sha1"
 	"fmt"
 	"io"
 	"reflect"
+	"runtime/debug"
+	"sync"
 	"time"
 )
 
@@ -17,13 +20,44 @@ type Schedule struct {
 	LastRun     time.Time
 	NextRun     time.Time
 	Duration    time.Duration
+	Cron        *Cron
+	// Location is the time zone in which recurrences are computed, defaults to time.Local.
+	// Cron expressions are matched against the wall clock of this location and durations
+	// of whole days keep the same wall clock time across daylight saving time transitions.
+	Location *time.Location
 }
 
-// Task holds information about task
+// Task holds information about task.
+// Changes made to the schedule by Run and ScheduleNextRun are guarded, use IsDue and
+// CurrentSchedule to read the schedule of a task which may be running concurrently.
 type Task struct {
 	Schedule
 	Func   FunctionMeta
 	Params []Param
+	// ID is the ID chosen by the caller, the ID is derived from the task's attributes when it's empty.
+	ID ID
+	// Retry is the policy used to retry failed runs, failed runs aren't retried when it's nil.
+	Retry *RetryPolicy
+	// Attempt is the number of failed attempts of the run which is being retried.
+	Attempt int
+	// Timeout limits the duration of a single execution, the context passed to
+	// the function is cancelled once it expires. Zero means no timeout.
+	Timeout time.Duration
+	// Overlap defines what happens when the task is due while it's still running.
+	Overlap OverlapPolicy
+	// Misfire defines what happens to runs dispatched more than MisfireGrace after they were due.
+	// The scheduler's policy and grace are used when they are not set.
+	Misfire      MisfirePolicy
+	MisfireGrace time.Duration
+	// CatchUpLimit caps the number of missed runs executed by MisfireFireAllMissed, zero means no limit.
+	CatchUpLimit int
+	// Paused is set while the task is paused, its runs aren't dispatched.
+	Paused bool
+	// Modified is set once the task's schedule or params were changed through the scheduler, after a restart
+	// the stored ones take precedence over the ones the task is registered with again.
+	Modified bool
+
+	mu sync.RWMutex
 }
 
 // New returns an instance of task
@@ -45,40 +79,267 @@ func NewWithSchedule(function FunctionMeta, params []Param, schedule Schedule) *
 
 // IsDue returns a boolean indicating whether the task should execute or not
 func (task *Task) IsDue() bool {
+	task.mu.RLock()
+	defer task.mu.RUnlock()
+
 	timeNow := time.Now()
 	return timeNow == task.NextRun || timeNow.After(task.NextRun)
 }
 
+// Result holds the outcome of a single execution of a task.
+type Result struct {
+	TaskID   ID
+	FuncName string
+	// Attempt is the attempt number of the run, starting at 1 and increasing with every retry.
+	Attempt int
+	// ScheduledAt is the time at which the run was due, or was triggered for manual runs.
+	ScheduledAt time.Time
+	StartedAt   time.Time
+	FinishedAt  time.Time
+	// Value is the non-error value returned by the function, if any.
+	Value interface{}
+	// Err is the error returned by the function, if its last return value is an error.
+	Err error
+	// Manual is set for runs which were triggered manually rather than by the task's schedule.
+	Manual bool
+}
+
+// Panicked reports whether the execution failed because the function panicked.
+func (result Result) Panicked() bool {
+	_, ok := result.Err.(*PanicError)
+	return ok
+}
+
+// PanicError is the error recorded in the result when the task's function panics.
+type PanicError struct {
+	// Value is the value the function panicked with.
+	Value interface{}
+	// Stack is the stack trace of the goroutine at the time of the panic.
+	Stack []byte
+}
+
+func (err *PanicError) Error() string {
+	return fmt.Sprintf("Task panicked: %v", err.Value)
+}
+
+// Duration returns how long the execution took.
+func (result Result) Duration() time.Duration {
+	return result.FinishedAt.Sub(result.StartedAt)
+}
+
+// Succeeded reports whether the execution completed without an error.
+func (result Result) Succeeded() bool {
+	return result.Err == nil
+}
+
 // Run will execute the task and schedule it's next run.
-func (task *Task) Run() {
+func (task *Task) Run() Result {
 	// Reschedule task first to prevent running the task
 	// again in case the execution time takes more than the
 	// task's duration value.
-	task.scheduleNextRun()
+	task.ScheduleNextRun()
+	return task.Call()
+}
+
+// Call executes the task's function with its params, without touching the schedule.
+// A panic raised by the function is recovered and returned as a *PanicError in the result.
+func (task *Task) Call() Result {
+	return task.CallWithContext(context.Background())
+}
+
+// CallWithContext executes the task's function like Call. The context is passed to
+// functions whose first parameter is a context.Context.
+func (task *Task) CallWithContext(ctx context.Context) (result Result) {
+	result = Result{
+		FuncName:  task.Func.Name,
+		StartedAt: time.Now(),
+	}
+	defer func() {
+		if value := recover(); value != nil {
+			result.FinishedAt = time.Now()
+			result.Value = nil
+			result.Err = &PanicError{
+				Value: value,
+				Stack: debug.Stack(),
+			}
+		}
+	}()
+
+	task.mu.RLock()
+	taskParams := task.Params
+	task.mu.RUnlock()
 
 	function := reflect.ValueOf(task.Func.function)
-	params := make([]reflect.Value, len(task.Params))
-	for i, param := range task.Params {
-		params[i] = reflect.ValueOf(param)
+	params := make([]reflect.Value, 0, len(taskParams)+1)
+	if task.Func.AcceptsContext() {
+		params = append(params, reflect.ValueOf(&ctx).Elem())
+	}
+	for _, param := range taskParams {
+		params = append(params, reflect.ValueOf(param))
+	}
+	values := function.Call(params)
+
+	result.FinishedAt = time.Now()
+	result.Value, result.Err = task.Func.results(values)
+	return result
+}
+
+// Clone returns a copy of the task which can be changed without affecting the task.
+func (task *Task) Clone() *Task {
+	task.mu.RLock()
+	defer task.mu.RUnlock()
+
+	return &Task{
+		Schedule:     task.Schedule,
+		Func:         task.Func,
+		Params:       append([]Param(nil), task.Params...),
+		ID:           task.ID,
+		Retry:        task.Retry,
+		Attempt:      task.Attempt,
+		Timeout:      task.Timeout,
+		Overlap:      task.Overlap,
+		Misfire:      task.Misfire,
+		MisfireGrace: task.MisfireGrace,
+		CatchUpLimit: task.CatchUpLimit,
+		Paused:       task.Paused,
+		Modified:     task.Modified,
 	}
-	function.Call(params)
 }
 
-// Hash will return the SHA1 representation of the task's data.
+// Update copies the schedule, the params, the ID, the retry state and the modified flag of a changed
+// copy of the task made by Clone. Executions which already started keep the previous params.
+func (task *Task) Update(changed *Task) {
+	changed.mu.RLock()
+	defer changed.mu.RUnlock()
+	task.mu.Lock()
+	defer task.mu.Unlock()
+
+	task.Schedule = changed.Schedule
+	task.Params = changed.Params
+	task.ID = changed.ID
+	task.Attempt = changed.Attempt
+	task.Modified = changed.Modified
+}
+
+// CurrentSchedule returns a copy of the task's schedule.
+func (task *Task) CurrentSchedule() Schedule {
+	task.mu.RLock()
+	defer task.mu.RUnlock()
+
+	return task.Schedule
+}
+
+// Hash will return the ID of the task, which is the SHA1 representation of the task's data
+// unless the task was given an ID. The data of one-off tasks includes the time at which they
+// are due, to the second, so that the same call scheduled at different times makes distinct tasks.
 func (task *Task) Hash() ID {
+	if task.ID != "" {
+		return task.ID
+	}
 	hash := sha1.New()
 	_, _ = io.WriteString(hash, task.Func.Name)
 	_, _ = io.WriteString(hash, fmt.Sprintf("%+v", task.Params))
 	_, _ = io.WriteString(hash, fmt.Sprintf("%s", task.Schedule.Duration))
 	_, _ = io.WriteString(hash, fmt.Sprintf("%t", task.Schedule.IsRecurring))
+	if !task.Schedule.IsRecurring {
+		_, _ = io.WriteString(hash, task.dueAt().UTC().Format(time.RFC3339))
+	}
+	if task.Schedule.Cron != nil {
+		_, _ = io.WriteString(hash, task.Schedule.Cron.String())
+	}
+	if task.Schedule.Location != nil {
+		_, _ = io.WriteString(hash, task.Schedule.Location.String())
+	}
 	return ID(fmt.Sprintf("%x", hash.Sum(nil)))
 }
 
-func (task *Task) scheduleNextRun() {
+// dueAt returns the time at which the run of a one-off task is due. Retries move the task's
+// NextRun while the run being retried is kept as its LastRun.
+func (task *Task) dueAt() time.Time {
+	task.mu.RLock()
+	defer task.mu.RUnlock()
+
+	if task.Attempt > 0 {
+		return task.LastRun
+	}
+	return task.NextRun
+}
+
+// ScheduleNextRun moves the schedule of a recurring task to its next run.
+func (task *Task) ScheduleNextRun() {
+	task.mu.Lock()
+	defer task.mu.Unlock()
+
 	if !task.IsRecurring {
 		return
 	}
 
+	// A retry doesn't move the cadence, resume from the run being retried.
+	if task.Attempt > 0 {
+		task.NextRun = task.Schedule.Next(task.LastRun)
+		return
+	}
+
 	task.LastRun = task.NextRun
-	task.NextRun = task.NextRun.Add(task.Duration)
+	task.NextRun = task.Schedule.Next(task.NextRun)
+}
+
+// ScheduleNextRunAfter moves the schedule of a recurring task to its first run after the
+// provided time, skipping the runs which were missed in between while keeping the cadence.
+func (task *Task) ScheduleNextRunAfter(now time.Time) {
+	task.mu.Lock()
+	defer task.mu.Unlock()
+
+	if !task.IsRecurring {
+		return
+	}
+
+	from := task.LastRun
+	if task.Attempt == 0 {
+		task.LastRun = task.NextRun
+		from = task.NextRun
+	}
+	task.NextRun = task.Schedule.nextAfter(from, now)
+}
+
+// nextAfter computes the first run following the one at from which is after now.
+func (schedule *Schedule) nextAfter(from, now time.Time) time.Time {
+	if schedule.Cron == nil && !schedule.isCalendarDays() && schedule.Duration > 0 && from.Before(now) {
+		// Plain durations are skipped at once rather than one run at a time
+		missed := now.Sub(from) / schedule.Duration
+		from = from.Add(missed * schedule.Duration)
+	}
+	next := schedule.Next(from)
+	for !next.IsZero() && !next.After(now) {
+		following := schedule.Next(next)
+		if !following.After(next) {
+			break
+		}
+		next = following
+	}
+	return next
+}
+
+// Next computes the run following the one at the provided time.
+func (schedule *Schedule) Next(after time.Time) time.Time {
+	loc := schedule.location()
+	if schedule.Cron != nil {
+		return schedule.Cron.Next(after.In(loc))
+	}
+	if schedule.isCalendarDays() {
+		return addCalendarDays(after, int(schedule.Duration/(24*time.Hour)), loc)
+	}
+	return after.Add(schedule.Duration)
+}
+
+// isCalendarDays reports whether the schedule's duration is a number of calendar days in its location.
+func (schedule *Schedule) isCalendarDays() bool {
+	return schedule.Location != nil && schedule.Duration > 0 && schedule.Duration%(24*time.Hour) == 0
+}
+
+func (schedule *Schedule) location() *time.Location {
+	if schedule.Location != nil {
+		return schedule.Location
+	}
+	return time.Local
 }
@@ -1,6 +1,9 @@
 package task
 
 import (
+	"context"
+	"errors"
+	"sync"
 	"testing"
 	"time"
 )
@@ -48,6 +51,76 @@ func TestTaskRunWithArgs(t *testing.T) {
 	mock.AssertExpectations(t)
 }
 
+func TestTaskCallWithContext(t *testing.T) {
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "value")
+
+	mock := CallbackMock{}
+	mock.On("CallWithContext", ctx, "Test").Return()
+
+	task := newTestTask(t, mock.CallWithContext, []Param{"Test"})
+	task.CallWithContext(ctx)
+
+	mock.AssertExpectations(t)
+}
+
+func TestTaskRunWithError(t *testing.T) {
+	mock := CallbackMock{}
+	mock.On("CallWithError", "Test").Return(errors.New("Failed"))
+
+	task := newTestTask(t, mock.CallWithError, []Param{"Test"})
+	result := task.Run()
+
+	if result.Succeeded() || result.Err.Error() != "Failed" {
+		t.Error("The returned error should be part of the result")
+	}
+	if result.Value != nil {
+		t.Error("Function returning an error only should have no result value")
+	}
+	if result.FinishedAt.Before(result.StartedAt) || result.FuncName != task.Func.Name {
+		t.Error("Result should describe the execution")
+	}
+
+	mock.AssertExpectations(t)
+}
+
+func TestTaskRunWithResult(t *testing.T) {
+	mock := CallbackMock{}
+	mock.On("CallWithResult").Return(42, nil).Once()
+	mock.On("CallWithResult").Return(0, errors.New("Failed")).Once()
+
+	task := newTestTask(t, mock.CallWithResult, []Param{})
+	result := task.Run()
+	if !result.Succeeded() || result.Value != 42 {
+		t.Error("The returned value should be part of the result")
+	}
+
+	result = task.Run()
+	if result.Succeeded() {
+		t.Error("The returned error should be part of the result")
+	}
+
+	mock.AssertExpectations(t)
+}
+
+func TestTaskRunWithPanic(t *testing.T) {
+	mock := CallbackMock{}
+	mock.On("CallWithPanic").Return()
+
+	task := newTestTask(t, mock.CallWithPanic, []Param{})
+	result := task.Run()
+
+	if !result.Panicked() || result.Succeeded() {
+		t.Fatal("A panic should be recovered and recorded as a failure")
+	}
+	panicErr := result.Err.(*PanicError)
+	if panicErr.Value != "CallWithPanic" || len(panicErr.Stack) == 0 {
+		t.Error("The panic value and stack trace should be recorded")
+	}
+
+	mock.AssertExpectations(t)
+}
+
 func TestTaskRunScheduledNextRun(t *testing.T) {
 	mock := CallbackMock{}
 	mock.On("CallNoArgs").Return()
@@ -66,6 +139,114 @@ func TestTaskRunScheduledNextRun(t *testing.T) {
 	mock.AssertExpectations(t)
 }
 
+func TestTaskRunScheduledCalendarDays(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("Time zone database is not available")
+	}
+	mock := CallbackMock{}
+	mock.On("CallNoArgs").Return()
+
+	task := newTestTask(t, mock.CallNoArgs, []Param{})
+	task.IsRecurring = true
+	task.Location = berlin
+	task.NextRun = time.Date(2018, time.October, 27, 9, 0, 0, 0, berlin)
+	task.Duration = 24 * time.Hour
+	task.Run()
+
+	if !task.NextRun.Equal(time.Date(2018, time.October, 28, 9, 0, 0, 0, berlin)) {
+		t.Error("Daily task should keep its wall clock time across DST, got", task.NextRun)
+	}
+
+	// Without a location the duration is added as is
+	task.Location = nil
+	task.NextRun = time.Date(2018, time.October, 27, 9, 0, 0, 0, berlin)
+	task.Run()
+	if !task.NextRun.Equal(time.Date(2018, time.October, 28, 8, 0, 0, 0, berlin)) {
+		t.Error("Task without a location should run every 24 hours, got", task.NextRun)
+	}
+
+	mock.AssertExpectations(t)
+}
+
+func TestScheduleNextSkippedWallClock(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("Time zone database is not available")
+	}
+	saoPaulo, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		t.Skip("Time zone database is not available")
+	}
+
+	// Clocks jump from 02:00 to 03:00, the skipped run fires at the transition.
+	schedule := Schedule{IsRecurring: true, Duration: 24 * time.Hour, Location: newYork}
+	next := schedule.Next(time.Date(2024, time.March, 9, 2, 30, 0, 0, newYork))
+	if !next.Equal(time.Date(2024, time.March, 10, 3, 0, 0, 0, newYork)) {
+		t.Error("Skipped wall clock time should run at the transition, got", next)
+	}
+
+	// Clocks jump from 00:00 to 01:00
+	schedule.Location = saoPaulo
+	next = schedule.Next(time.Date(2018, time.November, 3, 0, 30, 0, 0, saoPaulo))
+	if !next.Equal(time.Date(2018, time.November, 4, 1, 0, 0, 0, saoPaulo)) {
+		t.Error("Skipped wall clock time should run at the transition, got", next)
+	}
+}
+
+func TestTaskScheduleNextRunAfter(t *testing.T) {
+	mock := CallbackMock{}
+	nextRun := time.Date(2018, time.October, 27, 9, 0, 0, 0, time.UTC)
+
+	task := newTestTask(t, mock.CallNoArgs, []Param{})
+	task.IsRecurring = true
+	task.NextRun = nextRun
+	task.Duration = 5 * time.Minute
+	task.ScheduleNextRunAfter(nextRun.Add(12 * time.Minute))
+
+	if !task.LastRun.Equal(nextRun) {
+		t.Error("LastRun should be the run which was dispatched, got", task.LastRun)
+	}
+	if !task.NextRun.Equal(nextRun.Add(15 * time.Minute)) {
+		t.Error("Missed runs should be skipped while keeping the cadence, got", task.NextRun)
+	}
+
+	cron, _ := ParseCron("0 9 * * *")
+	task.Cron = cron
+	task.Location = time.UTC
+	task.NextRun = nextRun
+	task.ScheduleNextRunAfter(nextRun.Add(50 * time.Hour))
+	if !task.NextRun.Equal(time.Date(2018, time.October, 30, 9, 0, 0, 0, time.UTC)) {
+		t.Error("Missed cron runs should be skipped, got", task.NextRun)
+	}
+}
+
+func TestTaskConcurrentRun(t *testing.T) {
+	task := newTestTask(t, func() {}, []Param{})
+	task.IsRecurring = true
+	task.NextRun = time.Now()
+	task.Duration = time.Millisecond
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			task.Run()
+		}()
+		go func() {
+			defer wg.Done()
+			_ = task.IsDue()
+			_ = task.CurrentSchedule()
+		}()
+	}
+	wg.Wait()
+
+	if schedule := task.CurrentSchedule(); schedule.NextRun != schedule.LastRun.Add(time.Millisecond) {
+		t.Error("Concurrent runs should leave a consistent schedule")
+	}
+}
+
 func TestGenerateHash(t *testing.T) {
 	mock := CallbackMock{}
 	task := newTestTask(t, mock.CallNoArgs, []Param{})
@@ -77,6 +258,35 @@ func TestGenerateHash(t *testing.T) {
 	if hash == "" {
 		t.Fail()
 	}
+
+	task.ID = "invoice-reminder-42"
+	if task.Hash() != "invoice-reminder-42" {
+		t.Error("The ID given to the task should be used as its hash")
+	}
+}
+
+func TestOneOffHash(t *testing.T) {
+	mock := CallbackMock{}
+	dueAt := time.Date(2018, time.October, 27, 9, 0, 0, 0, time.UTC)
+	task := newTestTask(t, mock.CallWithArgs, []Param{"Hello", true})
+	task.NextRun = dueAt
+	other := newTestTask(t, mock.CallWithArgs, []Param{"Hello", true})
+	other.NextRun = dueAt.Add(time.Hour)
+
+	if task.Hash() == other.Hash() {
+		t.Error("The same call scheduled at different times should have distinct hashes")
+	}
+
+	other.NextRun = dueAt.In(time.FixedZone("UTC+2", 2*60*60))
+	if task.Hash() != other.Hash() {
+		t.Error("The hash should not depend on the time zone of the due time")
+	}
+
+	hash := task.Hash()
+	task.ScheduleRetry(dueAt.Add(time.Minute))
+	if task.Hash() != hash {
+		t.Error("Retrying the task should keep its hash")
+	}
 }
 
 func newTestTask(t *testing.T, function Function, params []Param) *Task {
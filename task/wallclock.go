@@ -0,0 +1,46 @@
+package task
+
+import "time"
+
+// wallClock returns the wall clock reading of t as a UTC time, which makes calendar
+// arithmetic on it immune to daylight saving time transitions.
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// resolveWallClock returns the instant at which the wall clock in loc reads the same as wall.
+//
+// Wall clock times which are skipped by a daylight saving time transition (e.g. 02:30 when clocks
+// jump from 02:00 to 03:00) resolve to the instant of the transition. Wall clock times which occur
+// twice (e.g. 02:30 when clocks fall back from 03:00 to 02:00) resolve to their first occurrence.
+func resolveWallClock(wall time.Time, loc *time.Location) time.Time {
+	t := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), loc)
+
+	zoneStart, zoneEnd := t.ZoneBounds()
+	if zoneStart.IsZero() {
+		return t
+	}
+	if resolved := wallClock(t); !resolved.Equal(wall) {
+		// The wall clock time was skipped, run as soon as the clocks moved forward. Depending on the zone,
+		// t is normalized to either side of the transition.
+		if resolved.Before(wall) && !zoneEnd.IsZero() {
+			return zoneEnd
+		}
+		return zoneStart
+	}
+
+	_, offset := t.Zone()
+	_, previousOffset := zoneStart.Add(-time.Nanosecond).Zone()
+	if previousOffset > offset {
+		earlier := t.Add(-time.Duration(previousOffset-offset) * time.Second)
+		if earlier.Before(zoneStart) && wallClock(earlier).Equal(wall) {
+			return earlier
+		}
+	}
+	return t
+}
+
+// addCalendarDays adds the number of days to t while keeping the wall clock time in loc.
+func addCalendarDays(t time.Time, days int, loc *time.Location) time.Time {
+	return resolveWallClock(wallClock(t.In(loc)).AddDate(0, 0, days), loc)
+}
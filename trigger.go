@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"fmt"
+
+	"github.com/rakanalh/scheduler/task"
+)
+
+// TriggerOptions configures a manual run of a task.
+type TriggerOptions struct {
+	// ResetSchedule makes a recurring task resume its cadence from the manual run, so that its
+	// next run is one recurrence after it. It has no effect on one-off tasks.
+	ResetSchedule bool
+}
+
+// TriggerNow executes the task's function with its params right away, without waiting for its next run.
+// The manual run doesn't affect the task's schedule unless asked to and isn't retried, its result is
+// marked as manual. Overlap policies and function limits don't apply to manual runs, but they wait
+// for a worker like other runs and fail with ErrQueueFull when the worker pool's queue is full.
+// Paused tasks can be triggered.
+func (scheduler *Scheduler) TriggerNow(taskID task.ID, opts TriggerOptions) error {
+	scheduler.mu.Lock()
+	defer scheduler.unlock()
+
+	if scheduler.stopped {
+		return ErrStopped
+	}
+	t, found := scheduler.tasks[taskID]
+	if !found {
+		return fmt.Errorf("Task not found")
+	}
+
+	now := scheduler.clock.Now()
+	var changed *task.Task
+	if opts.ResetSchedule && t.IsRecurring {
+		changed = t.Clone()
+		changed.LastRun = now
+		changed.NextRun = changed.Schedule.Next(now)
+		changed.Attempt = 0
+		if scheduler.started {
+			if err := scheduler.writeStore((*storeBridge).Update, changed); err != nil {
+				return err
+			}
+		}
+	}
+
+	exec := scheduler.startExecution(taskID, t, now)
+	exec.attempt = 1
+	exec.manual = true
+	if !scheduler.submit(exec) {
+		scheduler.discardExecution(exec)
+		return ErrQueueFull
+	}
+
+	if changed != nil {
+		t.Update(changed)
+		delete(scheduler.caughtUp, taskID)
+		if !t.Paused {
+			scheduler.queue.schedule(taskID, t)
+			scheduler.wake()
+		}
+	}
+	return nil
+}